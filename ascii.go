@@ -0,0 +1,162 @@
+package stinger
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// histogramRows and histogramWidth are the size of the printed histogram.
+	histogramRows  = 20
+	histogramWidth = 40
+	// heatmapRows and heatmapColumns bound the size of the printed heatmap.
+	heatmapRows    = 10
+	heatmapColumns = 60
+)
+
+// heatmapShades are cell characters from empty to the most populated.
+const heatmapShades = " .:-=+*#%@"
+
+// PrintOption adds optional sections to Print.
+type PrintOption func(*printOptions)
+
+type printOptions struct {
+	histogram bool
+	heatmap   bool
+}
+
+// WithHistogram prints the bucketed latency distribution.
+func WithHistogram() PrintOption {
+	return func(o *printOptions) {
+		o.histogram = true
+	}
+}
+
+// WithHeatmap prints latency distributions of timeline intervals, time goes
+// left to right and latency grows upwards.
+func WithHeatmap() PrintOption {
+	return func(o *printOptions) {
+		o.heatmap = true
+	}
+}
+
+// latencyBounds splits [lo, hi] into n log-spaced ranges and returns their upper bounds.
+func latencyBounds(lo, hi time.Duration, n int) []time.Duration {
+	lo = max(lo, 1)
+	if hi <= lo {
+		return []time.Duration{hi}
+	}
+
+	res := make([]time.Duration, n)
+	ratio := float64(hi) / float64(lo)
+	for i := range res {
+		res[i] = time.Duration(float64(lo) * math.Pow(ratio, float64(i+1)/float64(n)))
+	}
+	res[n-1] = hi
+
+	return res
+}
+
+// rangeCounts returns the number of observations of h within each range.
+func rangeCounts(h *Histogram, bounds []time.Duration) []int64 {
+	res := make([]int64, len(bounds))
+	var below int64
+	for i, b := range bounds {
+		n := h.CountBelow(b)
+		res[i] = n - below
+		below = n
+	}
+
+	return res
+}
+
+// latencyHistogram draws h as horizontal bars, one line per latency range.
+func latencyHistogram(h *Histogram) []string {
+	if h.Count() == 0 {
+		return nil
+	}
+
+	bounds := latencyBounds(h.Min(), h.Max(), histogramRows)
+	counts := rangeCounts(h, bounds)
+
+	var top int64
+	for _, c := range counts {
+		top = max(top, c)
+	}
+
+	res := make([]string, len(bounds))
+	low := h.Min()
+	for i, b := range bounds {
+		bar := int(math.Ceil(float64(counts[i]) / float64(top) * histogramWidth))
+		res[i] = fmt.Sprintf("%10s - %-10s |%-*s| %d", low, b, histogramWidth, strings.Repeat("#", bar), counts[i])
+		low = b
+	}
+
+	return res
+}
+
+// latencyHeatmap draws latency of the intervals, columns merge neighbour
+// intervals to fit the width and rows are latency ranges, the slowest on top.
+func latencyHeatmap(timeline []Interval) []string {
+	all := mergeLatency(timeline)
+	if all.Count() == 0 {
+		return nil
+	}
+
+	per := (len(timeline) + heatmapColumns - 1) / heatmapColumns
+	columns := make([]*Histogram, 0, heatmapColumns)
+	for start := 0; start < len(timeline); start += per {
+		columns = append(columns, mergeLatency(timeline[start:min(start+per, len(timeline))]))
+	}
+
+	bounds := latencyBounds(all.Min(), all.Max(), heatmapRows)
+	cells := make([][]int64, len(columns))
+	var top int64
+	for j, c := range columns {
+		cells[j] = rangeCounts(c, bounds)
+		for _, n := range cells[j] {
+			top = max(top, n)
+		}
+	}
+
+	res := make([]string, 0, len(bounds)+1)
+	for i := len(bounds) - 1; i >= 0; i-- {
+		row := make([]byte, len(columns))
+		for j := range columns {
+			shade := int(math.Ceil(float64(cells[j][i]) / float64(top) * float64(len(heatmapShades)-1)))
+			row[j] = heatmapShades[shade]
+		}
+		res = append(res, fmt.Sprintf("%10s |%s|", bounds[i], row))
+	}
+
+	step := timeline[0].Duration * time.Duration(per)
+	res = append(res, fmt.Sprintf("%10s  %d columns x %s", "", len(columns), step))
+
+	return res
+}
+
+func (r *Result) printHistogram() {
+	lines := latencyHistogram(r.LatencyHistogram())
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println("\nLATENCY HISTOGRAM:")
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+}
+
+func (r *Result) printHeatmap() {
+	lines := latencyHeatmap(r.timeline)
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println("\nLATENCY HEATMAP:")
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+}
@@ -0,0 +1,41 @@
+package stinger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	assert.Empty(t, latencyHistogram(NewHistogram()))
+
+	h := NewHistogram()
+	h.RecordN(time.Millisecond, 100)
+	h.RecordN(100*time.Millisecond, 50)
+
+	lines := latencyHistogram(h)
+	assert.Len(t, lines, histogramRows)
+	assert.True(t, strings.HasSuffix(lines[0], "|"+strings.Repeat("#", histogramWidth)+"| 100"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[len(lines)-1], "| 50"), lines[len(lines)-1])
+	for _, l := range lines[1 : len(lines)-1] {
+		assert.True(t, strings.HasSuffix(l, "| 0"), l)
+	}
+}
+
+func TestLatencyHeatmap(t *testing.T) {
+	assert.Empty(t, latencyHeatmap(nil))
+
+	timeline := make([]Interval, 3)
+	for i, d := range []time.Duration{time.Millisecond, 100 * time.Millisecond, time.Millisecond} {
+		timeline[i] = Interval{Duration: time.Second, Latency: NewHistogram()}
+		timeline[i].Latency.RecordN(d, 10)
+	}
+
+	lines := latencyHeatmap(timeline)
+	assert.Len(t, lines, heatmapRows+1)
+	assert.True(t, strings.HasSuffix(lines[0], "| @ |"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[heatmapRows-1], "|@ @|"), lines[heatmapRows-1])
+	assert.Contains(t, lines[heatmapRows], "3 columns x 1s")
+}
@@ -0,0 +1,125 @@
+package stinger
+
+import (
+	"sort"
+	"sync"
+)
+
+// CheckResult counts outcomes of a named check.
+type CheckResult struct {
+	Name   string
+	Passes int64
+	Fails  int64
+}
+
+// Rate returns the share (0..1) of passed checks.
+func (c CheckResult) Rate() float64 {
+	n := c.Passes + c.Fails
+	if n == 0 {
+		return 0
+	}
+
+	return float64(c.Passes) / float64(n)
+}
+
+// Validator checks the outcome of a request observed by ObserveRequest.
+type Validator func(code string, success bool, err error) bool
+
+type check struct {
+	name      string
+	validator Validator
+}
+
+// WithCheck runs the named check on the request outcome. Unlike the success
+// returned by the request, a failed check does not make the request failed.
+func WithCheck(name string, v Validator) RequestOption {
+	return func(r *requestInfo) {
+		r.checks = append(r.checks, check{name, v})
+	}
+}
+
+// checks orders check names by their first occurrence run-wide, outcomes
+// are counted by shards, which ask for the order once per name.
+type checks struct {
+	mu    sync.Mutex
+	index map[string]int
+}
+
+func newChecks() *checks {
+	return &checks{index: make(map[string]int)}
+}
+
+// order returns the number of names that occurred before name.
+func (c *checks) order(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, found := c.index[name]
+	if !found {
+		i = len(c.index)
+		c.index[name] = i
+	}
+
+	return i
+}
+
+// checkCount is a check result with its run-wide order.
+type checkCount struct {
+	order int
+	CheckResult
+}
+
+// checkSet counts check outcomes. It is not safe for concurrent use.
+type checkSet map[string]*checkCount
+
+func (s checkSet) get(name string, order func(string) int) *checkCount {
+	c, ok := s[name]
+	if !ok {
+		c = &checkCount{order: order(name), CheckResult: CheckResult{Name: name}}
+		s[name] = c
+	}
+
+	return c
+}
+
+func (s checkSet) record(name string, ok bool, order func(string) int) {
+	c := s.get(name, order)
+	if ok {
+		c.Passes++
+	} else {
+		c.Fails++
+	}
+}
+
+// merge adds outcomes counted by another shard.
+func (s checkSet) merge(o checkSet) {
+	for name, oc := range o {
+		c := s.get(name, func(string) int { return oc.order })
+		c.Passes += oc.Passes
+		c.Fails += oc.Fails
+	}
+}
+
+// snapshot returns the results in the run-wide order.
+func (s checkSet) snapshot() []CheckResult {
+	counts := make([]*checkCount, 0, len(s))
+	for _, c := range s {
+		counts = append(counts, c)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].order < counts[j].order })
+
+	res := make([]CheckResult, len(counts))
+	for i, c := range counts {
+		res[i] = c.CheckResult
+	}
+
+	return res
+}
+
+// Check records the outcome of the named check and returns ok. Checks do not
+// affect requests success and do not stop the actor.
+func (m *Metrics) Check(name string, ok bool) bool {
+	m.shard.check(name, ok, m.checks.order)
+
+	return ok
+}
@@ -0,0 +1,73 @@
+package stinger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecks(t *testing.T) {
+	m := newIsolatedMetrics()
+
+	isOK := WithCheck("status is OK", func(code string, _ bool, _ error) bool {
+		return code == "OK"
+	})
+	for i := range 4 {
+		_ = m.ObserveRequest(func() (string, bool, error) {
+			if i == 0 {
+				return "Unavailable", false, errors.New("unavailable")
+			}
+
+			m.Check("body is not empty", i != 1)
+
+			return "OK", true, nil
+		}, isOK)
+	}
+
+	r := m.Result()
+	assert.Equal(t, []CheckResult{
+		{Name: "status is OK", Passes: 3, Fails: 1},
+		{Name: "body is not empty", Passes: 2, Fails: 1},
+	}, r.Checks())
+
+	c, ok := r.Check("status is OK")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.75, c.Rate(), 0.0001)
+	assert.Equal(t, int64(1), r.Errors(), "checks must not affect success")
+
+	_, ok = r.Check("missing")
+	assert.False(t, ok)
+	assert.Zero(t, CheckResult{}.Rate())
+}
+
+func TestChecksShards(t *testing.T) {
+	m := newIsolatedMetrics()
+	a, b := m.forActor(0, "a"), m.forActor(1, "b")
+
+	b.Check("second", true)
+	a.Check("first", false)
+	a.Check("second", false)
+	b.Check("first", true)
+	b.Check("first", true)
+
+	assert.Equal(t, []CheckResult{
+		{Name: "second", Passes: 1, Fails: 1},
+		{Name: "first", Passes: 2, Fails: 1},
+	}, m.Result().Checks(), "checks must keep the run-wide order of the first occurrence")
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	gathered := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != "checks_total" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			labels := metric.GetLabel()
+			gathered[labels[0].GetValue()+"/"+labels[1].GetValue()] = metric.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"first/true": 2, "first/false": 1, "second/true": 1, "second/false": 1}, gathered)
+}
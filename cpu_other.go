@@ -0,0 +1,20 @@
+//go:build !unix
+
+package stinger
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// cpuTime returns CPU time consumed by the process as estimated by the runtime,
+// which updates the estimate on every GC cycle only.
+func cpuTime() time.Duration {
+	s := []metrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+	}
+	metrics.Read(s)
+
+	return time.Duration((s[0].Value.Float64() - s[1].Value.Float64()) * float64(time.Second))
+}
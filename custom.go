@@ -0,0 +1,513 @@
+package stinger
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricKind is the aggregation of a user-defined metric.
+type MetricKind string
+
+const (
+	// CounterMetric sums added values.
+	CounterMetric MetricKind = "counter"
+	// GaugeMetric keeps the last set value.
+	GaugeMetric MetricKind = "gauge"
+	// RateMetric is the share of true values.
+	RateMetric MetricKind = "rate"
+	// TrendMetric is the distribution of added values.
+	TrendMetric MetricKind = "trend"
+)
+
+// Trend is a log-linear distribution of user-defined values of any sign and
+// magnitude, with the relative error of Histogram. It is not safe for
+// concurrent use.
+type Trend struct {
+	// neg and pos count values by the bucket of their magnitude.
+	neg   map[int]uint64
+	pos   map[int]uint64
+	zeros uint64
+
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func newTrend() *Trend {
+	return &Trend{neg: make(map[int]uint64), pos: make(map[int]uint64)}
+}
+
+// trendIndex returns the bucket of the magnitude v > 0, there are
+// 1<<histSubBits linear buckets per power of two.
+func trendIndex(v float64) int {
+	frac, exp := math.Frexp(v)
+
+	return exp<<histSubBits + int((2*frac-1)*(1<<histSubBits))
+}
+
+// trendMiddle returns the middle of the magnitude range of the bucket i.
+func trendMiddle(i int) float64 {
+	exp, sub := i>>histSubBits, i&(1<<histSubBits-1)
+
+	return math.Ldexp(1+(float64(sub)+0.5)/(1<<histSubBits), exp-1)
+}
+
+// add records v, which must be a finite number.
+func (t *Trend) add(v float64) {
+	switch {
+	case v > 0:
+		t.pos[trendIndex(v)]++
+	case v < 0:
+		t.neg[trendIndex(-v)]++
+	default:
+		t.zeros++
+	}
+
+	if t.count == 0 || v < t.min {
+		t.min = v
+	}
+	if t.count == 0 || v > t.max {
+		t.max = v
+	}
+	t.count++
+	t.sum += v
+}
+
+// merge adds all values of o into t.
+func (t *Trend) merge(o *Trend) {
+	if o.count == 0 {
+		return
+	}
+
+	for i, n := range o.neg {
+		t.neg[i] += n
+	}
+	for i, n := range o.pos {
+		t.pos[i] += n
+	}
+	t.zeros += o.zeros
+
+	if t.count == 0 || o.min < t.min {
+		t.min = o.min
+	}
+	if t.count == 0 || o.max > t.max {
+		t.max = o.max
+	}
+	t.count += o.count
+	t.sum += o.sum
+}
+
+func (t *Trend) Count() int64 {
+	return int64(t.count) //nolint:gosec
+}
+
+func (t *Trend) Min() float64 {
+	return t.min
+}
+
+func (t *Trend) Max() float64 {
+	return t.max
+}
+
+func (t *Trend) Sum() float64 {
+	return t.sum
+}
+
+func (t *Trend) Mean() float64 {
+	if t.count == 0 {
+		return 0
+	}
+
+	return t.sum / float64(t.count)
+}
+
+// Quantile returns the value below which q (0..1) of values fall.
+func (t *Trend) Quantile(q float64) float64 {
+	if t.count == 0 {
+		return 0
+	}
+
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+
+	rank := uint64(math.Ceil(q * float64(t.count)))
+	var seen uint64
+	// NOTE: the larger a negative magnitude, the smaller the value
+	for _, i := range slices.Backward(slices.Sorted(maps.Keys(t.neg))) {
+		if seen += t.neg[i]; seen >= rank {
+			return t.clamp(-trendMiddle(i))
+		}
+	}
+	if seen += t.zeros; seen >= rank {
+		return t.clamp(0)
+	}
+	for _, i := range slices.Sorted(maps.Keys(t.pos)) {
+		if seen += t.pos[i]; seen >= rank {
+			return t.clamp(trendMiddle(i))
+		}
+	}
+
+	return t.max
+}
+
+func (t *Trend) clamp(v float64) float64 {
+	return min(max(v, t.min), t.max)
+}
+
+// CustomMetric is a user-defined metric aggregated over the run or an interval.
+type CustomMetric struct {
+	Name string
+	Kind MetricKind
+	Tags []Tag
+	// Value is the counter sum, the last gauge value, the rate (0..1) or the trend mean.
+	Value float64
+	// Passes and Total count rate values.
+	Passes int64
+	Total  int64
+	// Trend is the distribution of trend values, nil for other kinds.
+	Trend *Trend
+
+	// at is the time of the last gauge value.
+	at time.Time
+}
+
+func (c *CustomMetric) copy() CustomMetric {
+	res := *c
+	res.Tags = append([]Tag(nil), c.Tags...)
+	if c.Trend != nil {
+		res.Trend = newTrend()
+		res.Trend.merge(c.Trend)
+		res.Value = res.Trend.Mean()
+	}
+
+	return res
+}
+
+type customKey struct {
+	kind MetricKind
+	name string
+	tags string
+}
+
+// customSet aggregates user-defined metrics. It is not safe for concurrent use.
+type customSet map[customKey]*CustomMetric
+
+func (s customSet) get(kind MetricKind, name string, tags []Tag) *CustomMetric {
+	k := customKey{kind, name, encodeTags(tags)}
+	c, ok := s[k]
+	if !ok {
+		c = &CustomMetric{Name: name, Kind: kind, Tags: tags}
+		if kind == TrendMetric {
+			c.Trend = newTrend()
+		}
+		s[k] = c
+	}
+
+	return c
+}
+
+// observe adds v observed at ts to the metric. Tags must be sorted.
+func (s customSet) observe(ts time.Time, kind MetricKind, name string, tags []Tag, v float64) {
+	c := s.get(kind, name, tags)
+
+	switch kind {
+	case CounterMetric:
+		c.Value += v
+	case GaugeMetric:
+		c.Value = v
+		c.at = ts
+	case RateMetric:
+		c.Total++
+		if v != 0 {
+			c.Passes++
+		}
+		c.Value = float64(c.Passes) / float64(c.Total)
+	case TrendMetric:
+		c.Trend.add(v)
+	}
+}
+
+// merge adds metrics recorded by another shard, the latest gauge value wins.
+func (s customSet) merge(o customSet) {
+	for _, oc := range o {
+		c := s.get(oc.Kind, oc.Name, oc.Tags)
+
+		switch oc.Kind {
+		case CounterMetric:
+			c.Value += oc.Value
+		case GaugeMetric:
+			if c.at.IsZero() || oc.at.After(c.at) {
+				c.Value = oc.Value
+				c.at = oc.at
+			}
+		case RateMetric:
+			c.Passes += oc.Passes
+			c.Total += oc.Total
+			c.Value = float64(c.Passes) / float64(c.Total)
+		case TrendMetric:
+			c.Trend.merge(oc.Trend)
+		}
+	}
+}
+
+// snapshot returns copies of the metrics sorted by name, kind and tags.
+func (s customSet) snapshot() []CustomMetric {
+	keys := make([]customKey, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+
+		return keys[i].tags < keys[j].tags
+	})
+
+	res := make([]CustomMetric, len(keys))
+	for i, k := range keys {
+		res[i] = s[k].copy()
+	}
+
+	return res
+}
+
+func sortedTags(tags []Tag) []Tag {
+	res := append([]Tag(nil), tags...)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Key != res[j].Key {
+			return res[i].Key < res[j].Key
+		}
+
+		return res[i].Value < res[j].Value
+	})
+
+	return res
+}
+
+// AddCounter adds v to the counter name.
+func (m *Metrics) AddCounter(name string, v float64, tags ...Tag) {
+	m.observeCustom(CounterMetric, name, tags, v)
+}
+
+// SetGauge sets the gauge name to v.
+func (m *Metrics) SetGauge(name string, v float64, tags ...Tag) {
+	m.observeCustom(GaugeMetric, name, tags, v)
+}
+
+// AddRate adds a value to the rate name, which is the share of true values.
+func (m *Metrics) AddRate(name string, ok bool, tags ...Tag) {
+	var v float64
+	if ok {
+		v = 1
+	}
+
+	m.observeCustom(RateMetric, name, tags, v)
+}
+
+// AddTrend adds v to the distribution name.
+func (m *Metrics) AddTrend(name string, v float64, tags ...Tag) {
+	m.observeCustom(TrendMetric, name, tags, v)
+}
+
+// observeCustom records v unless it is not a finite number or name is
+// already used by another kind, a name has a single kind for the whole run.
+// Rejected values are counted.
+func (m *Metrics) observeCustom(kind MetricKind, name string, tags []Tag, v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		m.rejectedMetrics.Add(1)
+
+		return
+	}
+	if k, _ := m.customKinds.LoadOrStore(name, kind); k != kind {
+		m.rejectedMetrics.Add(1)
+
+		return
+	}
+
+	m.shard.observeCustom(time.Now(), kind, name, sortedTags(tags), v)
+}
+
+// customPoints flattens user-defined metrics of an interval into points.
+func customPoints(metrics []CustomMetric) []point {
+	res := make([]point, 0, len(metrics))
+	for _, c := range metrics {
+		labels := make([]label, len(c.Tags))
+		for i, t := range c.Tags {
+			labels[i] = label{t.Key, t.Value}
+		}
+
+		switch c.Kind {
+		case CounterMetric:
+			res = append(res, point{c.Name, labels, c.Value, true})
+		case GaugeMetric, RateMetric:
+			res = append(res, point{c.Name, labels, c.Value, false})
+		case TrendMetric:
+			res = append(res,
+				point{c.Name + "_min", labels, c.Trend.Min(), false},
+				point{c.Name + "_mean", labels, c.Trend.Mean(), false},
+				point{c.Name + "_p50", labels, c.Trend.Quantile(0.5), false},
+				point{c.Name + "_p90", labels, c.Trend.Quantile(0.9), false},
+				point{c.Name + "_p95", labels, c.Trend.Quantile(0.95), false},
+				point{c.Name + "_p99", labels, c.Trend.Quantile(0.99), false},
+				point{c.Name + "_max", labels, c.Trend.Max(), false},
+			)
+		}
+	}
+
+	return res
+}
+
+var promInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// promName makes s a valid Prometheus metric or label name.
+func promName(s string) string {
+	s = promInvalidChars.ReplaceAllString(s, "_")
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		s = "_" + s
+	}
+
+	return s
+}
+
+// customPrefix namespaces user-defined metrics in the Prometheus registry,
+// so they never clash with the built-in ones.
+const customPrefix = "custom_"
+
+// customFamilyNames returns the names a family of the kind is exposed under.
+func customFamilyNames(name string, kind MetricKind) []string {
+	switch kind {
+	case RateMetric:
+		return []string{name, name + "_total"}
+	case TrendMetric:
+		return []string{name, name + "_sum", name + "_count"}
+	default:
+		return []string{name}
+	}
+}
+
+// customCollector exposes user-defined metrics in the Prometheus registry, so
+// exporters gathering it get them too. Metrics of the same name get the union
+// of their tag keys as labels, absent tags are empty. Names are sanitized,
+// so different ones may collide, the family sorted first wins.
+type customCollector struct {
+	r *recorder
+}
+
+// Describe sends nothing, which makes the collector unchecked.
+func (c *customCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *customCollector) Collect(ch chan<- prometheus.Metric) {
+	families := make(map[string][]CustomMetric)
+	names := make([]string, 0)
+	for _, cm := range c.r.snapshot().custom {
+		name := promName(customPrefix + cm.Name)
+		if _, ok := families[name]; !ok {
+			names = append(names, name)
+		}
+		families[name] = append(families[name], cm)
+	}
+	sort.Strings(names)
+
+	reserved := make(map[string]bool)
+	for _, name := range names {
+		family := families[name]
+
+		kind := family[0].Kind
+		exposed := customFamilyNames(name, kind)
+		if slices.ContainsFunc(exposed, func(n string) bool { return reserved[n] }) {
+			continue
+		}
+		for _, n := range exposed {
+			reserved[n] = true
+		}
+
+		family = slices.DeleteFunc(family, func(cm CustomMetric) bool { return cm.Kind != kind })
+		collectCustom(ch, name, family)
+	}
+}
+
+// collectCustom sends a family of metrics with the same sanitized name and
+// kind. Metrics whose label values repeat those already sent are dropped.
+func collectCustom(ch chan<- prometheus.Metric, name string, family []CustomMetric) {
+	seen := make(map[string]bool)
+	labels := make([]string, 0)
+	for _, c := range family {
+		for _, t := range c.Tags {
+			if l := promName(t.Key); !seen[l] {
+				seen[l] = true
+				labels = append(labels, l)
+			}
+		}
+	}
+	sort.Strings(labels)
+
+	help := fmt.Sprintf("user-defined %s", family[0].Kind)
+	desc := prometheus.NewDesc(name, help, labels, nil)
+	totalDesc := prometheus.NewDesc(name+"_total", help+" values number", labels, nil)
+
+	sent := make(map[string]bool)
+	for _, c := range family {
+		values := make([]string, len(labels))
+		set := make([]bool, len(labels))
+		for _, t := range c.Tags {
+			i, _ := slices.BinarySearch(labels, promName(t.Key))
+			if !set[i] {
+				values[i], set[i] = t.Value, true
+			}
+		}
+
+		key := strings.Join(values, "\xff")
+		if sent[key] {
+			continue
+		}
+		sent[key] = true
+
+		switch c.Kind {
+		case CounterMetric:
+			sendConst(ch)(prometheus.NewConstMetric(desc, prometheus.CounterValue, c.Value, values...))
+		case GaugeMetric:
+			sendConst(ch)(prometheus.NewConstMetric(desc, prometheus.GaugeValue, c.Value, values...))
+		case RateMetric:
+			sendConst(ch)(prometheus.NewConstMetric(desc, prometheus.GaugeValue, c.Value, values...))
+			sendConst(ch)(prometheus.NewConstMetric(totalDesc, prometheus.CounterValue, float64(c.Total), values...))
+		case TrendMetric:
+			quantiles := map[float64]float64{
+				0.5:  c.Trend.Quantile(0.5),
+				0.9:  c.Trend.Quantile(0.9),
+				0.95: c.Trend.Quantile(0.95),
+				0.99: c.Trend.Quantile(0.99),
+			}
+			//nolint:gosec
+			sendConst(ch)(prometheus.NewConstSummary(desc, uint64(c.Trend.Count()), c.Trend.Sum(), quantiles, values...))
+		}
+	}
+}
+
+// sendConst returns a function sending a const metric to ch, metrics failed
+// to build, e.g. with a reserved label name, are skipped rather than panic
+// the whole Gather.
+func sendConst(ch chan<- prometheus.Metric) func(prometheus.Metric, error) {
+	return func(m prometheus.Metric, err error) {
+		if err == nil {
+			ch <- m
+		}
+	}
+}
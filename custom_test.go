@@ -0,0 +1,140 @@
+package stinger
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomMetrics(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+
+	am := m.forActor(0, "cache")
+	for i := range 4 {
+		am.AddRate("cache.hit", i%2 == 0)
+		am.AddCounter("items", 10, Tag{"region", "eu"}, Tag{"kind", "batch"})
+		am.AddTrend("batch_size", float64(i+1))
+	}
+	am.AddCounter("items", 1, Tag{"region", "us"})
+	am.SetGauge("queue_depth", 5)
+	am.SetGauge("queue_depth", 3)
+
+	m.StopTimer()
+	r := m.Result()
+
+	items, ok := r.Metric("items", Tag{"kind", "batch"}, Tag{"region", "eu"})
+	assert.True(t, ok)
+	assert.Equal(t, CounterMetric, items.Kind)
+	assert.InDelta(t, 40, items.Value, 0.001)
+
+	_, ok = r.Metric("items", Tag{"region", "eu"})
+	assert.False(t, ok)
+
+	hit, ok := r.Metric("cache.hit")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.5, hit.Value, 0.001)
+	assert.Equal(t, int64(2), hit.Passes)
+	assert.Equal(t, int64(4), hit.Total)
+
+	depth, _ := r.Metric("queue_depth")
+	assert.InDelta(t, 3, depth.Value, 0.001)
+
+	size, _ := r.Metric("batch_size")
+	assert.Equal(t, int64(4), size.Trend.Count())
+	assert.InDelta(t, 2.5, size.Trend.Mean(), 0.1)
+	assert.InDelta(t, 4, size.Trend.Max(), 0.15)
+	assert.InDelta(t, 2.5, size.Value, 0.1)
+
+	timeline := r.Timeline()
+	assert.Len(t, timeline, 1)
+	assert.Len(t, timeline[0].Custom, 5)
+
+	points := make(map[string]point)
+	for _, p := range intervalPoints(timeline[0]) {
+		points[p.name] = p
+	}
+	assert.True(t, points["items"].counter)
+	assert.InDelta(t, 0.5, points["cache.hit"].value, 0.001)
+	assert.InDelta(t, 4, points["batch_size_max"].value, 0.15)
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	gathered := make(map[string]int)
+	for _, f := range families {
+		gathered[f.GetName()] = len(f.GetMetric())
+	}
+	assert.Equal(t, 2, gathered["custom_items"], "items must be gathered with both tag sets")
+	assert.Equal(t, 1, gathered["custom_cache_hit"])
+	assert.Equal(t, 1, gathered["custom_cache_hit_total"])
+	assert.Equal(t, 1, gathered["custom_batch_size"])
+}
+
+func TestCustomMetricsClash(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+
+	m.IncReq(1)
+	m.AddCounter("requests_total", 1)
+	m.AddCounter("x", 1)
+	m.SetGauge("x", 2)
+	m.AddCounter("a.b", 1, Tag{"k.1", "a"}, Tag{"k_1", "b"})
+	m.AddCounter("a_b", 2, Tag{"k_1", "a"})
+	m.AddRate("hit", true)
+	m.AddCounter("hit_total", 3)
+	m.AddCounter("tags", 1, Tag{"__name__", "x"})
+
+	m.StopTimer()
+
+	x, ok := m.Result().Metric("x")
+	assert.True(t, ok)
+	assert.Equal(t, CounterMetric, x.Kind, "the first kind of a name must win")
+	assert.InDelta(t, 1, x.Value, 0.001)
+	assert.Equal(t, uint64(1), m.Result().RejectedMetrics(), "values of another kind must be counted")
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	gathered := make(map[string]float64)
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			gathered[f.GetName()] += metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+		}
+	}
+	assert.InDelta(t, 1, gathered["requests_total"], 0.001)
+	assert.InDelta(t, 1, gathered["custom_requests_total"], 0.001)
+	assert.InDelta(t, 1, gathered["custom_x"], 0.001)
+	assert.InDelta(t, 1, gathered["custom_a_b"], 0.001, "only the first of clashing sanitized names must be gathered")
+	assert.InDelta(t, 1, gathered["custom_hit_total"], 0.001, "the rate total must win over a clashing counter")
+}
+
+func TestCustomMetricsTrend(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+
+	for _, v := range []float64{-2e15, -1, -1e-9, 0, 1e-9, 3, 5e12} {
+		m.AddTrend("delta", v)
+	}
+	m.AddTrend("delta", math.NaN())
+	m.AddTrend("delta", math.Inf(1))
+
+	m.StopTimer()
+	r := m.Result()
+	assert.Equal(t, uint64(2), r.RejectedMetrics(), "values other than finite numbers must be rejected")
+
+	delta, ok := r.Metric("delta")
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), delta.Trend.Count())
+	assert.InDelta(t, -2e15, delta.Trend.Min(), 0)
+	assert.InDelta(t, 5e12, delta.Trend.Max(), 0)
+	assert.InEpsilon(t, (-2e15+5e12+2)/7, delta.Trend.Mean(), 1e-9)
+
+	assert.InEpsilon(t, -1, delta.Trend.Quantile(0.2), 0.03)
+	assert.InEpsilon(t, -1e-9, delta.Trend.Quantile(0.4), 0.03)
+	assert.Zero(t, delta.Trend.Quantile(0.5))
+	assert.InEpsilon(t, 1e-9, delta.Trend.Quantile(0.65), 0.03)
+	assert.InEpsilon(t, 3, delta.Trend.Quantile(0.8), 0.03)
+	assert.InEpsilon(t, 5e12, delta.Trend.Quantile(0.99), 0.03)
+}
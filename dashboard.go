@@ -0,0 +1,197 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// dashboardWidth is the number of intervals in the throughput sparkline.
+	dashboardWidth = 60
+	// dashboardWindow is the number of intervals of rolling percentiles.
+	dashboardWindow = 10
+	// dashboardErrors is the number of the most frequent error groups shown.
+	dashboardErrors = 5
+
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+)
+
+var sparks = []rune(" ▁▂▃▄▅▆▇█")
+
+// dashboard renders live metrics of the run. On a terminal it redraws a full
+// screen frame, otherwise it writes a status line per interval.
+type dashboard struct {
+	NopObserver
+
+	w        io.Writer
+	m        *Metrics
+	ansi     bool
+	duration time.Duration
+
+	// history keeps the last complete intervals.
+	history []Interval
+}
+
+// isTerminal reports whether f is a character device, e.g. an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// newDashboard renders the run to w every interval, the run is expected to
+// last the duration.
+func newDashboard(w io.Writer, duration time.Duration) *dashboard {
+	d := &dashboard{w: w, duration: duration}
+	if f, ok := w.(*os.File); ok {
+		d.ansi = isTerminal(f)
+	}
+
+	return d
+}
+
+func (d *dashboard) OnStart(_ context.Context, m *Metrics) {
+	d.m = m
+	if d.ansi {
+		fmt.Fprint(d.w, ansiHideCursor)
+	}
+}
+
+func (d *dashboard) OnIntervalSnapshot(_ context.Context, intervals []Interval) {
+	d.update(intervals)
+	fmt.Fprint(d.w, d.render(time.Now()))
+}
+
+func (d *dashboard) OnFinish(context.Context, *Result) {
+	if d.ansi {
+		fmt.Fprint(d.w, ansiShowCursor)
+	}
+}
+
+// update keeps the last complete intervals.
+func (d *dashboard) update(intervals []Interval) {
+	d.history = append(d.history, intervals...)
+	if len(d.history) > dashboardWidth {
+		d.history = append([]Interval(nil), d.history[len(d.history)-dashboardWidth:]...)
+	}
+}
+
+func (d *dashboard) render(now time.Time) string {
+	if d.ansi {
+		return d.frame(now)
+	}
+
+	return d.line(now)
+}
+
+// rolling returns the latency of the last intervals and the latest throughput.
+func (d *dashboard) rolling() (*Histogram, float64) {
+	latency := NewHistogram()
+	for _, i := range d.history[max(len(d.history)-dashboardWindow, 0):] {
+		latency.Merge(i.Latency)
+	}
+
+	var throughput float64
+	if len(d.history) > 0 {
+		throughput = d.history[len(d.history)-1].Throughput()
+	}
+
+	return latency, throughput
+}
+
+func (d *dashboard) elapsed(now time.Time) string {
+	elapsed := now.Sub(d.m.start).Truncate(time.Second)
+	if d.duration <= 0 {
+		return elapsed.String()
+	}
+
+	return fmt.Sprintf("%s/%s", elapsed, d.duration)
+}
+
+// line is the plain status line.
+func (d *dashboard) line(now time.Time) string {
+	latency, throughput := d.rolling()
+
+	var errors int64
+	for _, g := range d.m.errors.snapshot() {
+		errors += g.Count
+	}
+
+	return fmt.Sprintf("elapsed %s throughput %0.2f req/s p(50) %s p(99) %s in-flight %d errors %d\n",
+		d.elapsed(now), throughput, latency.Quantile(0.5), latency.Quantile(0.99), d.m.Inflight(), errors)
+}
+
+// frame is the full screen dashboard.
+func (d *dashboard) frame(now time.Time) string {
+	latency, throughput := d.rolling()
+	b := &strings.Builder{}
+
+	b.WriteString(ansiClear)
+	fmt.Fprintf(b, "%sSTINGER%s elapsed %s", ansiBold, ansiReset, d.elapsed(now))
+	if d.duration > 0 {
+		remaining := max(d.duration-now.Sub(d.m.start), 0).Truncate(time.Second)
+		fmt.Fprintf(b, " remaining %s", remaining)
+	}
+	b.WriteString("\n\n")
+
+	fmt.Fprintf(b, "throughput .................... %0.2f req/s\n", throughput)
+	fmt.Fprintf(b, "  %s\n", sparkline(d.history))
+	fmt.Fprintf(b, "in-flight ..................... %d\n", d.m.Inflight())
+	fmt.Fprintf(b, "latency ....................... p(50) %s p(90) %s p(95) %s p(99) %s\n",
+		latency.Quantile(0.5), latency.Quantile(0.9), latency.Quantile(0.95), latency.Quantile(0.99))
+
+	fmt.Fprintf(b, "\n%sCODES:%s\n", ansiBold, ansiReset)
+	for _, r := range d.m.Responses() {
+		name := r.Code
+		if !r.Success {
+			name += " (failed)"
+		}
+		fmt.Fprintf(b, "%s %s %d\n", name, getSpacer(name, 30), r.Count)
+	}
+
+	if groups := d.m.errors.snapshot(); len(groups) > 0 {
+		fmt.Fprintf(b, "\n%sERRORS:%s\n", ansiBold, ansiReset)
+		for _, g := range groups[:min(len(groups), dashboardErrors)] {
+			fmt.Fprintf(b, "%d x %s\n", g.Count, g.Message)
+		}
+	}
+
+	return b.String()
+}
+
+// sparkline draws throughput of the intervals scaled to the maximum.
+func sparkline(intervals []Interval) string {
+	values := make([]float64, len(intervals))
+	for j, i := range intervals {
+		values[j] = i.Throughput()
+	}
+
+	return sparkValues(values)
+}
+
+// sparkValues draws non-negative values scaled to the maximum.
+func sparkValues(values []float64) string {
+	var top float64
+	for _, v := range values {
+		top = max(top, v)
+	}
+
+	res := make([]rune, len(values))
+	for j, v := range values {
+		k := 0
+		if top > 0 && v > 0 {
+			k = int(v / top * float64(len(sparks)-1))
+		}
+		res[j] = sparks[k]
+	}
+
+	return string(res)
+}
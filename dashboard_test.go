@@ -0,0 +1,64 @@
+package stinger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparkline(t *testing.T) {
+	intervals := []Interval{
+		{Duration: time.Second},
+		{Duration: time.Second, Responses: []Response{{Count: 4}}},
+		{Duration: time.Second, Responses: []Response{{Count: 8}}},
+	}
+
+	assert.Equal(t, " ▄█", sparkline(intervals))
+}
+
+func TestDashboard(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.SetInterval(time.Millisecond)
+	m.StartTimer()
+
+	_ = m.ObserveRequest(func() (string, bool, error) {
+		return "OK", true, nil
+	})
+	m.ObserveError(errors.New("connection refused"))
+	time.Sleep(3 * time.Millisecond)
+
+	d := &dashboard{m: m, duration: time.Minute}
+	d.update(m.Timeline())
+	assert.NotEmpty(t, d.history)
+
+	line := d.render(time.Now())
+	assert.True(t, strings.HasPrefix(line, "elapsed 0s/1m0s throughput"), line)
+	assert.Contains(t, line, "errors 1\n")
+
+	d.ansi = true
+	frame := d.render(time.Now())
+	assert.True(t, strings.HasPrefix(frame, ansiClear))
+	assert.Contains(t, frame, "remaining 59s")
+	assert.Contains(t, frame, "OK ")
+	assert.Contains(t, frame, "1 x connection refused")
+}
+
+func TestDashboardPlain(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.SetInterval(time.Millisecond)
+	m.StartTimer()
+
+	w := &bytes.Buffer{}
+	d := newDashboard(w, 0)
+	d.OnStart(context.Background(), m)
+	d.OnIntervalSnapshot(context.Background(), m.Timeline())
+	d.OnFinish(context.Background(), m.Result())
+
+	assert.NotContains(t, w.String(), "\x1b")
+	assert.Contains(t, w.String(), "in-flight 0")
+}
@@ -0,0 +1,150 @@
+package stinger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// errorSamples is the number of distinct raw messages kept per group.
+	errorSamples = 3
+	// maxErrorGroups bounds the number of groups, the rest is counted in the overflow group.
+	maxErrorGroups = 100
+
+	overflowErrorGroup = "other errors"
+)
+
+// ErrorGroup is a set of errors of the same type with the same normalized message.
+type ErrorGroup struct {
+	Type    string
+	Message string
+	Count   int64
+	First   time.Time
+	Last    time.Time
+	// Samples are a few distinct raw messages.
+	Samples []string
+}
+
+var errorNormalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:]*\](:\d+)?`), "<addr>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<addr>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "<hex>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]*(?:\d[0-9a-f]*[a-f]|[a-f][0-9a-f]*\d)[0-9a-f]*\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<n>"},
+}
+
+// normalizeError strips variable parts, like addresses and numbers, from the message.
+func normalizeError(msg string) string {
+	for _, n := range errorNormalizers {
+		msg = n.re.ReplaceAllString(msg, n.repl)
+	}
+
+	return msg
+}
+
+// errorType returns the type of the innermost wrapped error.
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+type errorKey struct {
+	typ     string
+	message string
+}
+
+type errorGroups struct {
+	mu     sync.Mutex
+	groups map[errorKey]*ErrorGroup
+}
+
+func newErrorGroups() *errorGroups {
+	return &errorGroups{groups: make(map[errorKey]*ErrorGroup)}
+}
+
+// record adds err to its group and reports whether the group is new.
+func (g *errorGroups) record(ts time.Time, err error) bool {
+	msg := err.Error()
+	k := errorKey{errorType(err), normalizeError(msg)}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[k]
+	if !ok && len(g.groups) >= maxErrorGroups {
+		k = errorKey{"", overflowErrorGroup}
+		group, ok = g.groups[k]
+	}
+
+	if !ok {
+		group = &ErrorGroup{Type: k.typ, Message: k.message, First: ts}
+		g.groups[k] = group
+	}
+
+	group.Count++
+	group.Last = ts
+	if len(group.Samples) < errorSamples && !contains(group.Samples, msg) {
+		group.Samples = append(group.Samples, msg)
+	}
+
+	return !ok
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// snapshot returns copies of the groups, the most frequent first.
+func (g *errorGroups) snapshot() []ErrorGroup {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	res := make([]ErrorGroup, 0, len(g.groups))
+	for _, group := range g.groups {
+		c := *group
+		c.Samples = append([]string(nil), group.Samples...)
+		res = append(res, c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+
+		return res[i].First.Before(res[j].First)
+	})
+
+	return res
+}
+
+// ObserveError adds err to the error groups reported in Result.
+func (m *Metrics) ObserveError(err error) {
+	m.observeError(err)
+}
+
+func (m *Metrics) observeError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return m.errors.record(time.Now(), err)
+}
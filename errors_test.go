@@ -0,0 +1,72 @@
+package stinger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{ after time.Duration }
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.after)
+}
+
+func TestNormalizeError(t *testing.T) {
+	cases := map[string]string{
+		"dial tcp 10.0.0.1:8080: connect: connection refused": "dial tcp <addr>: connect: connection refused",
+		"dial tcp [::1]:8080: connect: connection refused":    "dial tcp <addr>: connect: connection refused",
+		"user 42 not found": "user <n> not found",
+		"request 3f2b9c1e-0a4d-4e1b-9f7a-2c6d8e0b1a3f failed": "request <uuid> failed",
+		"bad pointer 0xc000123abc":                            "bad pointer <hex>",
+		"object 5f1a9e2b missing":                             "object <hex> missing",
+		"deadline exceeded":                                   "deadline exceeded",
+	}
+
+	for in, want := range cases {
+		assert.Equal(t, want, normalizeError(in), in)
+	}
+}
+
+func TestErrorGroups(t *testing.T) {
+	m := newIsolatedMetrics()
+	start := time.Now()
+
+	for i := range 5 {
+		err := fmt.Errorf("call: %w", &timeoutError{time.Duration(i+1) * time.Second})
+		assert.Equal(t, i == 0, m.errors.record(start.Add(time.Duration(i)*time.Second), err))
+	}
+	assert.True(t, m.errors.record(start, errors.New("user 1 not found")))
+	assert.False(t, m.errors.record(start, errors.New("user 1 not found")))
+	m.ObserveError(nil)
+
+	groups := m.Result().ErrorGroups()
+	assert.Len(t, groups, 2)
+
+	assert.Equal(t, "*stinger.timeoutError", groups[0].Type)
+	assert.Equal(t, "call: timed out after <n>s", groups[0].Message)
+	assert.Equal(t, int64(5), groups[0].Count)
+	assert.Equal(t, start, groups[0].First)
+	assert.Equal(t, start.Add(4*time.Second), groups[0].Last)
+	assert.Equal(t, []string{"call: timed out after 1s", "call: timed out after 2s", "call: timed out after 3s"}, groups[0].Samples)
+
+	assert.Equal(t, "*errors.errorString", groups[1].Type)
+	assert.Equal(t, int64(2), groups[1].Count)
+	assert.Equal(t, []string{"user 1 not found"}, groups[1].Samples)
+}
+
+func TestErrorGroupsOverflow(t *testing.T) {
+	g := newErrorGroups()
+	for i := range maxErrorGroups + 10 {
+		g.record(time.Now(), errors.New("error "+strings.Repeat("z", i+1)))
+	}
+
+	groups := g.snapshot()
+	assert.Len(t, groups, maxErrorGroups+1)
+	assert.Equal(t, overflowErrorGroup, groups[0].Message)
+	assert.Equal(t, int64(10), groups[0].Count)
+}
@@ -24,6 +24,18 @@ var (
 	procsFlag    = flag.Int("procs", 0, "number of procs")
 	durationFlag = flag.Duration("d", time.Second, "test duration")
 	verboseFlag  = flag.Bool("v", false, "verbose output")
+	samplesFlag  = flag.String("samples", "", "path to per-request sample log (.csv or .ndjson)")
+	asciiFlag    = flag.Bool("histogram", false, "print latency histogram and heatmap")
+	scrapeFlag   = flag.String("scrape", "", "target metrics url to scrape cpu and memory of the service from")
+	profileFlag  = flag.String("profile", "", "directory to write cpu, heap, mutex, block profiles and a trace of the generator to")
+	historyFlag  = flag.String("history", "", "directory of saved results to print the trend of the last runs")
+
+	pushgatewayFlag = flag.String("pushgateway", "", "pushgateway url to push metrics to")
+	remoteWriteFlag = flag.String("remote_write", "", "prometheus remote write url to push metrics to")
+	otlpFlag        = flag.String("otlp", "", "otlp/http collector url to push metrics to")
+	influxFlag      = flag.String("influx", "", "influxdb write url to stream intervals to")
+	graphiteFlag    = flag.String("graphite", "", "graphite host:port to stream intervals to")
+	statsdFlag      = flag.String("statsd", "", "statsd host:port to stream intervals to")
 
 	concurrencyFlag           = flag.Int("concurrency", 1, "concurrency")
 	clientsFlag               = flag.Int("clients", 1, "count of grpc clients for single uri")
@@ -40,16 +52,91 @@ func main() {
 	f := NewFaker()
 
 	m := stinger.NewMetrics()
+	if *samplesFlag != "" {
+		sw, err := stinger.NewSampleWriter(stinger.SampleWriterConfig{Path: *samplesFlag, Size: 1 << 16})
+		if err != nil {
+			panic(err)
+		}
+		defer sw.Close()
+
+		m.SetSampleSink(sw)
+	}
+
 	runners := make([]stinger.Runnable, 0)
 
+	exporters := make([]stinger.Exporter, 0)
+	if *pushgatewayFlag != "" {
+		exporters = append(exporters, stinger.NewPushgatewayExporter(stinger.PushgatewayConfig{URL: *pushgatewayFlag}))
+	}
+	if *remoteWriteFlag != "" {
+		exporters = append(exporters, stinger.NewRemoteWriteExporter(stinger.RemoteWriteConfig{URL: *remoteWriteFlag}))
+	}
+	if *otlpFlag != "" {
+		e, err := stinger.NewOTLPExporter(ctx, stinger.OTLPConfig{Endpoint: *otlpFlag})
+		if err != nil {
+			panic(err)
+		}
+		defer e.Shutdown(context.Background()) //nolint:errcheck
+
+		exporters = append(exporters, e)
+	}
+
+	outputs := make([]stinger.Output, 0)
+	if *influxFlag != "" {
+		o, err := stinger.NewInfluxOutput(stinger.InfluxConfig{URL: *influxFlag})
+		if err != nil {
+			panic(err)
+		}
+
+		outputs = append(outputs, o)
+	}
+	if *graphiteFlag != "" {
+		o := stinger.NewGraphiteOutput(stinger.GraphiteConfig{Addr: *graphiteFlag})
+		defer o.Close()
+
+		outputs = append(outputs, o)
+	}
+	if *statsdFlag != "" {
+		o, err := stinger.NewStatsDOutput(stinger.StatsDConfig{Addr: *statsdFlag})
+		if err != nil {
+			panic(err)
+		}
+		defer o.Close()
+
+		outputs = append(outputs, o)
+	}
+
 	gb := stinger.NewGrpcBencher(m, *concurrencyFlag, 1, *uriFlag)
 
 	runner := NewSayHelloBencher(gb, f)
 	runners = append(runners, runner)
+	var scrape []stinger.ScrapeTarget
+	if *scrapeFlag != "" {
+		scrape = append(scrape, stinger.ScrapeTarget{
+			URL:    *scrapeFlag,
+			Series: []string{"process_cpu_seconds_total", "process_resident_memory_bytes"},
+		})
+	}
+
+	var profile stinger.ProfileConfig
+	if *profileFlag != "" {
+		profile = stinger.ProfileConfig{
+			Kinds: []stinger.ProfileKind{
+				stinger.ProfileCPU, stinger.ProfileHeap, stinger.ProfileMutex, stinger.ProfileBlock, stinger.ProfileTrace,
+			},
+			Dir: *profileFlag,
+		}
+	}
+
 	r := stinger.Benchmark(ctx, m, stinger.BenchmarkConfig{
 		Procs:    *procsFlag,
 		Duration: *durationFlag,
 		Verbose:  *verboseFlag,
+
+		Exporters: exporters,
+		Outputs:   outputs,
+		Scrape:    scrape,
+		Profile:   profile,
 	}, runners...)
 
 	select {
@@ -58,7 +145,28 @@ func main() {
 	default:
 	}
 
-	r.Print()
+	if *asciiFlag {
+		r.Print(stinger.WithHistogram(), stinger.WithHeatmap())
+	} else {
+		r.Print()
+	}
+
+	if *historyFlag != "" {
+		h, err := stinger.OpenHistory(*historyFlag)
+		if err != nil {
+			panic(err)
+		}
+
+		if _, err := h.Save("say_hello", r); err != nil {
+			panic(err)
+		}
+
+		trend, err := h.Trend("say_hello", 10)
+		if err != nil {
+			panic(err)
+		}
+		trend.Print()
+	}
 }
 
 type SayHelloBencher struct {
@@ -83,7 +191,7 @@ func (b *SayHelloBencher) ActorSetup(ctx context.Context, id int) (stinger.Actor
 }
 
 type SayHelloActor struct {
-	p *stinger.RRContainer[pb.GreeterClient]
+	p *stinger.RRContainer[Greeter]
 	g stinger.Generator[*pb.HelloRequest]
 }
 
@@ -93,8 +201,9 @@ func (a *SayHelloActor) Run(m *stinger.Metrics) error {
 		return stinger.ErrEndOfData
 	}
 
+	client := a.next()
 	err := m.ObserveRequest(func() (string, bool, error) {
-		_, err := a.next().SayHello(context.Background(), &pb.HelloRequest{
+		_, err := client.SayHello(context.Background(), &pb.HelloRequest{
 			Name: req.Name,
 		})
 		if err != nil {
@@ -105,12 +214,16 @@ func (a *SayHelloActor) Run(m *stinger.Metrics) error {
 		}
 
 		return codes.OK.String(), true, nil
-	})
+	},
+		stinger.WithTags(stinger.Tag{Key: "method", Value: "SayHello"}),
+		stinger.WithTarget(client.target),
+		stinger.WithPayload("name="+req.Name),
+	)
 
 	return err
 }
 
-func (a *SayHelloActor) next() pb.GreeterClient {
+func (a *SayHelloActor) next() Greeter {
 	return a.p.Next()
 }
 
@@ -138,10 +251,15 @@ func (rf *Faker) Next() *pb.HelloRequest {
 	}
 }
 
-func NewGreeterClient(conns []*grpc.ClientConn) []pb.GreeterClient {
-	clients := make([]pb.GreeterClient, len(conns))
+type Greeter struct {
+	pb.GreeterClient
+	target string
+}
+
+func NewGreeterClient(conns []*grpc.ClientConn) []Greeter {
+	clients := make([]Greeter, len(conns))
 	for i, conn := range conns {
-		clients[i] = pb.NewGreeterClient(conn)
+		clients[i] = Greeter{pb.NewGreeterClient(conn), conn.Target()}
 	}
 
 	return clients
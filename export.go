@@ -0,0 +1,106 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultExportInterval = 10 * time.Second
+
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter ships benchmark metrics to an external system.
+type Exporter interface {
+	Export(context.Context, *Metrics) error
+}
+
+// exportObserver runs exporters every interval during the run and makes the
+// final export once it is finished.
+type exportObserver struct {
+	NopObserver
+
+	interval  time.Duration
+	exporters []Exporter
+
+	m    *Metrics
+	stop func()
+}
+
+func (o *exportObserver) OnStart(_ context.Context, m *Metrics) {
+	o.m = m
+}
+
+func (o *exportObserver) OnStageChange(ctx context.Context, s Stage) {
+	if s == StageRun {
+		o.stop = startExporters(ctx, o.m, o.interval, o.exporters)
+	}
+}
+
+func (o *exportObserver) OnFinish(context.Context, *Result) {
+	if o.stop != nil {
+		o.stop()
+	}
+}
+
+// startExporters runs exporters every interval until ctx is done. The returned
+// func stops the loop and makes the final export.
+func startExporters(ctx context.Context, m *Metrics, interval time.Duration, exporters []Exporter) func() {
+	if len(exporters) == 0 {
+		return func() {}
+	}
+
+	if interval <= 0 {
+		interval = DefaultExportInterval
+	}
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				export(ctx, m, exporters)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		export(finalContext(ctx), m, exporters)
+	}
+}
+
+// finalContext is the context of shipping the final state of the run, which
+// has to be shipped even if the run context is already canceled.
+func finalContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+func export(ctx context.Context, m *Metrics, exporters []Exporter) {
+	for _, e := range exporters {
+		ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+		err := e.Export(ctx, m)
+		cancel()
+
+		if err != nil {
+			fmt.Printf("export err: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package stinger
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func newObservedMetrics() *Metrics {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+	_ = m.ObserveRequest(func() (string, bool, error) {
+		return "OK", true, nil
+	})
+
+	return m
+}
+
+func TestPushgatewayExporter(t *testing.T) {
+	var method, path string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	e := NewPushgatewayExporter(PushgatewayConfig{
+		URL:      srv.URL,
+		Job:      "bench",
+		Grouping: map[string]string{"run": "1"},
+	})
+
+	err := e.Export(context.Background(), newObservedMetrics())
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/bench/run/1", path)
+	assert.Contains(t, string(body), "requests_total")
+}
+
+// decodeSeriesNames returns __name__ of every series of an encoded WriteRequest.
+func decodeSeriesNames(t *testing.T, b []byte) map[string]float64 {
+	t.Helper()
+
+	field := func(b []byte) (protowire.Number, []byte, []byte) {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			n = 8
+			v = b[:8]
+		default:
+			_, n = protowire.ConsumeVarint(b)
+		}
+		assert.GreaterOrEqual(t, n, 0)
+
+		return num, v, b[n:]
+	}
+
+	res := make(map[string]float64)
+	for len(b) > 0 {
+		var ts []byte
+		_, ts, b = field(b)
+
+		var name string
+		var value float64
+		for len(ts) > 0 {
+			var num protowire.Number
+			var v []byte
+			num, v, ts = field(ts)
+
+			for len(v) > 0 {
+				var n protowire.Number
+				var fv []byte
+				n, fv, v = field(v)
+
+				switch {
+				case num == 1 && n == 1 && string(fv) == "__name__":
+					_, fv, _ = field(v)
+					name = string(fv)
+				case num == 2 && n == 1:
+					value = math.Float64frombits(binary.LittleEndian.Uint64(fv))
+				}
+			}
+		}
+		res[name] = value
+	}
+
+	return res
+}
+
+func TestRemoteWriteExporter(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e := NewRemoteWriteExporter(RemoteWriteConfig{URL: srv.URL, Labels: map[string]string{"job": "bench"}})
+
+	err := e.Export(context.Background(), newObservedMetrics())
+	assert.NoError(t, err)
+	assert.Equal(t, "snappy", header.Get("Content-Encoding"))
+	assert.Equal(t, "0.1.0", header.Get("X-Prometheus-Remote-Write-Version"))
+
+	b, err := snappy.Decode(nil, body)
+	assert.NoError(t, err)
+
+	series := decodeSeriesNames(t, b)
+	assert.Equal(t, 1.0, series["requests_total"])
+	assert.Equal(t, 1.0, series["latency_count"])
+	assert.Contains(t, series, "responses_total")
+}
+
+func TestRemoteWriteLabels(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+	m.AddCounter("items", 1, Tag{"job", "actor"})
+	m.StopTimer()
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	e := NewRemoteWriteExporter(RemoteWriteConfig{Labels: map[string]string{"job": "bench", "__name__": "x", "env": "ci"}})
+	for _, s := range e.series(families) {
+		if s.labels[0].value != "custom_items" {
+			continue
+		}
+
+		assert.Equal(t, []label{{"__name__", "custom_items"}, {"env", "ci"}, {"job", "actor"}}, s.labels, "metric labels must win configured ones")
+
+		return
+	}
+	t.Fatal("custom_items is not exported")
+}
+
+func TestRemoteWriteExporterError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewRemoteWriteExporter(RemoteWriteConfig{URL: srv.URL}).Export(context.Background(), newObservedMetrics())
+	assert.ErrorContains(t, err, "out of order sample")
+}
+
+type countingExporter struct {
+	n atomic.Int64
+}
+
+func (e *countingExporter) Export(context.Context, *Metrics) error {
+	e.n.Add(1)
+
+	return nil
+}
+
+func TestStartExporters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &countingExporter{}
+
+	stop := startExporters(ctx, newIsolatedMetrics(), 10*time.Millisecond, []Exporter{e})
+	time.Sleep(35 * time.Millisecond)
+	cancel()
+	stop()
+
+	assert.GreaterOrEqual(t, e.n.Load(), int64(3), "periodic exports and the final one")
+}
@@ -0,0 +1,120 @@
+package stinger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var metricPathEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_", ":", "_", "|", "_", "@", "_", "#", "_")
+
+// flatName joins prefix, point name and its label values into a dotted metric path.
+func flatName(prefix string, p point) string {
+	var sb strings.Builder
+	if prefix != "" {
+		sb.WriteString(prefix)
+		sb.WriteByte('.')
+	}
+	sb.WriteString(p.name)
+
+	for _, l := range p.labels {
+		sb.WriteByte('.')
+		sb.WriteString(metricPathEscaper.Replace(l.value))
+	}
+
+	return sb.String()
+}
+
+type GraphiteConfig struct {
+	// Addr is host:port of the plaintext protocol listener.
+	Addr string
+	// Prefix is "stinger" if empty.
+	Prefix string
+}
+
+// GraphiteOutput writes intervals over Graphite plaintext protocol, reconnecting
+// on failure. Run labels and the revision are Graphite tags of every series.
+type GraphiteOutput struct {
+	cfg  GraphiteConfig
+	tags string
+
+	mu   *sync.Mutex
+	conn net.Conn
+}
+
+func NewGraphiteOutput(cfg GraphiteConfig) *GraphiteOutput {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "stinger"
+	}
+
+	return &GraphiteOutput{cfg: cfg, mu: &sync.Mutex{}}
+}
+
+var graphiteTagEscaper = strings.NewReplacer(";", "_", " ", "_", "~", "_", "=", "_")
+
+// setRunLabels renders labels as the tag suffix of every series.
+func (o *GraphiteOutput) setRunLabels(labels []label) {
+	var sb strings.Builder
+	for _, l := range labels {
+		sb.WriteByte(';')
+		sb.WriteString(graphiteTagEscaper.Replace(l.name))
+		sb.WriteByte('=')
+		sb.WriteString(graphiteTagEscaper.Replace(l.value))
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.tags = sb.String()
+}
+
+func (o *GraphiteOutput) WriteInterval(ctx context.Context, i Interval) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn == nil {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", o.cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("graphite: dial err: %w", err)
+		}
+
+		o.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = o.conn.SetWriteDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(o.conn)
+	ts := strconv.FormatInt(i.Start.Unix(), 10)
+	for _, p := range intervalPoints(i) {
+		fmt.Fprintf(w, "%s%s %s %s\n", flatName(o.cfg.Prefix, p), o.tags, strconv.FormatFloat(p.value, 'f', -1, 64), ts)
+	}
+
+	if err := w.Flush(); err != nil {
+		o.conn.Close()
+		o.conn = nil
+
+		return fmt.Errorf("graphite: write err: %w", err)
+	}
+
+	return nil
+}
+
+func (o *GraphiteOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn == nil {
+		return nil
+	}
+
+	err := o.conn.Close()
+	o.conn = nil
+
+	return err
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -25,6 +27,7 @@ type GrpcBencher struct {
 	m           *Metrics
 	parallelism int
 	clients     int
+	creds       credentials.TransportCredentials
 
 	slices [][]string
 }
@@ -32,15 +35,23 @@ type GrpcBencher struct {
 func NewGrpcBencher(m *Metrics, parallelism int, clients int, uri string) *GrpcBencher {
 	uris := strings.Split(uri, ",")
 
-	return &GrpcBencher{uris, m, parallelism, clients, nil}
+	return &GrpcBencher{uris, m, parallelism, clients, insecure.NewCredentials(), nil}
+}
+
+// SetTransportCredentials sets credentials of created clients, insecure by default.
+func (b *GrpcBencher) SetTransportCredentials(c credentials.TransportCredentials) {
+	b.creds = c
 }
 
 func (b *GrpcBencher) SetUp(_ context.Context) {
 	uris := MultiplySlice(b.uris, b.clients*b.parallelism)
 	Shuffle(uris)
 	b.slices = SplitSlice(uris, b.clients)
+}
 
-	b.m.Enable()
+// Targets returns the benchmarked URIs.
+func (b *GrpcBencher) Targets() []string {
+	return b.uris
 }
 
 func (b *GrpcBencher) Parallelism() int {
@@ -51,7 +62,12 @@ func (b *GrpcBencher) CreateClients(ctx context.Context, id int) ([]*grpc.Client
 	conCtx, cancel := context.WithTimeout(ctx, 10*time.Second) // FIXME: hardcode
 	defer cancel()
 
-	conns, err := NewGrpcConnections(conCtx, b.slices[id%len(b.slices)], b.m)
+	m := MetricsFromContext(ctx)
+	if m == nil {
+		m = b.m
+	}
+
+	conns, err := newGrpcConnections(conCtx, b.slices[id%len(b.slices)], m, b.creds)
 	if err != nil {
 		return nil, err
 	}
@@ -59,11 +75,18 @@ func (b *GrpcBencher) CreateClients(ctx context.Context, id int) ([]*grpc.Client
 	return conns, err
 }
 
-func newGrpcClient(_ context.Context, uri string, m *Metrics) (*grpc.ClientConn, error) {
+func newGrpcClient(_ context.Context, uri string, m *Metrics, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
+	target := uri
+	// NOTE: plain host:port is resolved by the dialer to measure DNS phase
+	if _, _, err := net.SplitHostPort(uri); err == nil && !strings.Contains(uri, "/") {
+		target = "passthrough:///" + uri
+	}
+
 	return grpc.NewClient(
-		uri,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithContextDialer(sizeObservation(m)),
+		target,
+		grpc.WithTransportCredentials(&phaseCredentials{creds, m}),
+		grpc.WithContextDialer(sizeObservation(m, uri)),
+		grpc.WithStatsHandler(&phaseStats{m}),
 		grpc.WithChainUnaryInterceptor(
 			clientMetrics.UnaryClientInterceptor(),
 		),
@@ -74,10 +97,14 @@ func newGrpcClient(_ context.Context, uri string, m *Metrics) (*grpc.ClientConn,
 }
 
 func NewGrpcConnections(ctx context.Context, uris []string, m *Metrics) ([]*grpc.ClientConn, error) {
+	return newGrpcConnections(ctx, uris, m, insecure.NewCredentials())
+}
+
+func newGrpcConnections(ctx context.Context, uris []string, m *Metrics, creds credentials.TransportCredentials) ([]*grpc.ClientConn, error) {
 	conns := make([]*grpc.ClientConn, len(uris))
 	for i, u := range uris {
 		// NOTE: make client creation blocking
-		conn, err := newGrpcClient(ctx, u, m)
+		conn, err := newGrpcClient(ctx, u, m, creds)
 		if err != nil {
 			return nil, fmt.Errorf("grpc new client for %s: %w", u, err)
 		}
@@ -88,23 +115,61 @@ func NewGrpcConnections(ctx context.Context, uris []string, m *Metrics) ([]*grpc
 	return conns, nil
 }
 
+// SizeObserverNetConn counts the connection traffic, see NewSizeObserverNetConn.
 type SizeObserverNetConn struct {
-	c net.Conn
-	m *Metrics
+	c        net.Conn
+	m        *Metrics
+	counters []*traffic
+	opened   time.Time
+	closed   atomic.Bool
+
+	sent     atomic.Uint64
+	received atomic.Uint64
 }
 
 func (c *SizeObserverNetConn) Read(b []byte) (int, error) {
-	return observingSizeRead(c.m, c.c, b)
+	n, err := c.c.Read(b)
+	if n > 0 {
+		v := uint64(n) //nolint:gosec
+		c.received.Add(v)
+		c.m.addTraffic(0, v, c.counters...)
+	}
+
+	return n, err
 }
 
 func (c *SizeObserverNetConn) Write(b []byte) (int, error) {
-	return observingSizeWrite(c.m, c.c, b)
+	n, err := c.c.Write(b)
+	if n > 0 {
+		v := uint64(n) //nolint:gosec
+		c.sent.Add(v)
+		c.m.addTraffic(v, 0, c.counters...)
+	}
+
+	return n, err
 }
 
 func (c *SizeObserverNetConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		lifetime := time.Since(c.opened)
+		for _, t := range c.counters {
+			t.closed(lifetime)
+		}
+	}
+
 	return c.c.Close()
 }
 
+// SentBytes returns the number of bytes written to the connection.
+func (c *SizeObserverNetConn) SentBytes() uint64 {
+	return c.sent.Load()
+}
+
+// ReceivedBytes returns the number of bytes read from the connection.
+func (c *SizeObserverNetConn) ReceivedBytes() uint64 {
+	return c.received.Load()
+}
+
 func (c *SizeObserverNetConn) LocalAddr() net.Addr {
 	return c.c.LocalAddr()
 }
@@ -125,14 +190,57 @@ func (c *SizeObserverNetConn) SetWriteDeadline(t time.Time) error {
 	return c.c.SetWriteDeadline(t)
 }
 
-// NOTE: very dirty.
-func sizeObservation(m *Metrics) func(context.Context, string) (net.Conn, error) {
+// sizeObservation dials connections counting their traffic to the target.
+func sizeObservation(m *Metrics, target string) func(context.Context, string) (net.Conn, error) {
 	return func(ctx context.Context, addr string) (net.Conn, error) {
-		c, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		addrs, err := resolve(ctx, m, addr)
 		if err != nil {
+			m.ObserveDialFailure(target)
+
 			return nil, err
 		}
 
-		return &SizeObserverNetConn{c, m}, nil
+		var c net.Conn
+		s := time.Now()
+		for _, a := range addrs {
+			c, err = (&net.Dialer{}).DialContext(ctx, "tcp", a)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			m.ObserveDialFailure(target)
+
+			return nil, err
+		}
+		m.ObservePhase(PhaseConnect, time.Since(s))
+
+		return NewSizeObserverNetConn(m, c, target), nil
 	}
 }
+
+// resolve looks up the host of addr, so DNS and connect phases are measured apart.
+func resolve(ctx context.Context, m *Metrics, addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+
+	s := time.Now()
+	hosts, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	m.ObservePhase(PhaseDNS, time.Since(s))
+
+	addrs := make([]string, len(hosts))
+	for i, h := range hosts {
+		addrs[i] = net.JoinHostPort(h, port)
+	}
+
+	return addrs, nil
+}
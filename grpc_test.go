@@ -0,0 +1,105 @@
+package stinger
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+)
+
+type greeterServer struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (s *greeterServer) SayHello(_ context.Context, r *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "Hello " + r.GetName()}, nil
+}
+
+func startGreeter(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	srv := grpc.NewServer()
+	pb.RegisterGreeterServer(srv, &greeterServer{})
+	go srv.Serve(l) //nolint:errcheck
+	t.Cleanup(srv.Stop)
+
+	return l.Addr().String()
+}
+
+func TestGrpcPhases(t *testing.T) {
+	_, port, err := net.SplitHostPort(startGreeter(t))
+	assert.NoError(t, err)
+
+	m := newIsolatedMetrics()
+	m.StartTimer()
+
+	conns, err := NewGrpcConnections(context.Background(), []string{"localhost:" + port}, m)
+	assert.NoError(t, err)
+	defer conns[0].Close()
+
+	c := pb.NewGreeterClient(conns[0])
+	for range 3 {
+		_, err := c.SayHello(context.Background(), &pb.HelloRequest{Name: "stinger"})
+		assert.NoError(t, err)
+	}
+
+	m.StopTimer()
+	r := m.Result()
+
+	assert.Equal(t, int64(1), r.PhaseHistogram(PhaseDNS).Count())
+	assert.Equal(t, int64(1), r.PhaseHistogram(PhaseConnect).Count())
+	assert.Equal(t, int64(0), r.PhaseHistogram(PhaseTLS).Count(), "insecure connection has no handshake")
+	assert.Equal(t, int64(3), r.PhaseHistogram(PhaseWrite).Count())
+	assert.Equal(t, int64(3), r.PhaseHistogram(PhaseFirstByte).Count())
+	assert.Equal(t, int64(3), r.PhaseHistogram(PhaseTransfer).Count())
+	assert.Positive(t, r.ReceivedBytes())
+}
+
+func TestGrpcTraffic(t *testing.T) {
+	addr := startGreeter(t)
+
+	m := newIsolatedMetrics()
+	m.StartTimer()
+	am := m.forActor(0, "hello")
+
+	conns, err := newGrpcConnections(context.Background(), []string{addr, "127.0.0.1:1"}, am, insecure.NewCredentials())
+	assert.NoError(t, err)
+
+	_, err = pb.NewGreeterClient(conns[0]).SayHello(context.Background(), &pb.HelloRequest{Name: "stinger"})
+	assert.NoError(t, err)
+	_, err = pb.NewGreeterClient(conns[1]).SayHello(context.Background(), &pb.HelloRequest{Name: "stinger"})
+	assert.Error(t, err)
+
+	for _, c := range conns {
+		assert.NoError(t, c.Close())
+	}
+
+	m.StopTimer()
+	r := m.Result()
+
+	targets := r.Targets()
+	assert.Len(t, targets, 2)
+	assert.Equal(t, "127.0.0.1:1", targets[0].Name)
+	assert.Positive(t, targets[0].DialFailures)
+	assert.Zero(t, targets[0].Connections)
+
+	assert.Equal(t, addr, targets[1].Name)
+	assert.Equal(t, int64(1), targets[1].Connections)
+	assert.Zero(t, targets[1].Active)
+	assert.Equal(t, int64(1), targets[1].Lifetime.Count())
+	assert.Positive(t, targets[1].SentBytes)
+	assert.Equal(t, r.ReceivedBytes(), targets[1].ReceivedBytes)
+
+	runners := r.Runners()
+	assert.Len(t, runners, 1)
+	assert.Equal(t, "hello", runners[0].Name)
+	assert.Equal(t, r.SentBytes(), runners[0].SentBytes)
+	assert.Equal(t, r.Traffic().DialFailures, runners[0].DialFailures)
+}
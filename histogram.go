@@ -0,0 +1,255 @@
+package stinger
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histSubBits sets the number of linear sub-buckets per power of two
+// (1<<histSubBits), which bounds the relative error to ~3%.
+const histSubBits = 5
+
+// Histogram is a log-linear latency histogram. It is not safe for concurrent use.
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    uint64
+	min    uint64
+	max    uint64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// histMaxIndex is the bucket index of the largest value.
+var histMaxIndex = histIndex(math.MaxUint64)
+
+// histogramJSON is the serialized Histogram, buckets are non-empty index/count pairs.
+type histogramJSON struct {
+	Count   uint64      `json:"count"`
+	Sum     uint64      `json:"sum"`
+	Min     uint64      `json:"min"`
+	Max     uint64      `json:"max"`
+	Buckets [][2]uint64 `json:"buckets"`
+}
+
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	v := histogramJSON{Count: h.count, Sum: h.sum, Min: h.min, Max: h.max, Buckets: make([][2]uint64, 0)}
+	for i, c := range h.counts {
+		if c > 0 {
+			v.Buckets = append(v.Buckets, [2]uint64{uint64(i), c})
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+func (h *Histogram) UnmarshalJSON(b []byte) error {
+	var v histogramJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	res := Histogram{count: v.Count, sum: v.Sum, min: v.Min, max: v.Max}
+	var total uint64
+	for _, b := range v.Buckets {
+		i, c := b[0], b[1]
+		if i > uint64(histMaxIndex) {
+			return fmt.Errorf("histogram: bucket index %d out of range", i)
+		}
+		if int(i) >= len(res.counts) {
+			res.grow(int(i) + 1)
+		}
+		res.counts[i] += c
+		total += c
+	}
+	if total != v.Count {
+		return fmt.Errorf("histogram: bucket counts sum %d, count %d", total, v.Count)
+	}
+
+	*h = res
+
+	return nil
+}
+
+func histIndex(v uint64) int {
+	if v < 1<<histSubBits {
+		return int(v)
+	}
+
+	shift := bits.Len64(v) - histSubBits - 1
+
+	return (shift+1)<<histSubBits + int((v>>shift)&(1<<histSubBits-1))
+}
+
+// histBounds returns the [low, high) value range of the bucket i.
+func histBounds(i int) (uint64, uint64) {
+	if i < 1<<histSubBits {
+		return uint64(i), uint64(i) + 1
+	}
+
+	shift := i>>histSubBits - 1
+	low := uint64(1<<histSubBits+i&(1<<histSubBits-1)) << shift
+
+	return low, low + 1<<shift
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	h.RecordN(d, 1)
+}
+
+// RecordN records the same value n times.
+func (h *Histogram) RecordN(d time.Duration, n uint64) {
+	if n == 0 {
+		return
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	v := uint64(d) //nolint:gosec
+	i := histIndex(v)
+	if i >= len(h.counts) {
+		h.grow(i + 1)
+	}
+
+	h.counts[i] += n
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.count += n
+	h.sum += v * n
+}
+
+func (h *Histogram) grow(n int) {
+	counts := make([]uint64, n)
+	copy(counts, h.counts)
+	h.counts = counts
+}
+
+// Merge adds all observations of o into h.
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil || o.count == 0 {
+		return
+	}
+
+	if len(o.counts) > len(h.counts) {
+		h.grow(len(o.counts))
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+}
+
+func (h *Histogram) Count() int64 {
+	return int64(h.count) //nolint:gosec
+}
+
+func (h *Histogram) Min() time.Duration {
+	return time.Duration(h.min) //nolint:gosec
+}
+
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) //nolint:gosec
+}
+
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(h.sum) //nolint:gosec
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	return time.Duration(h.sum / h.count) //nolint:gosec
+}
+
+// Quantile returns the value below which q (0..1) of observations fall.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	if q <= 0 {
+		return h.Min()
+	}
+	if q >= 1 {
+		return h.Max()
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			low, high := histBounds(i)
+			v := low + (high-low)/2
+			v = max(v, h.min)
+			v = min(v, h.max)
+
+			return time.Duration(v) //nolint:gosec
+		}
+	}
+
+	return h.Max()
+}
+
+// CountBelow returns the number of observations not above d, within the
+// bucket resolution.
+func (h *Histogram) CountBelow(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+
+	last := min(histIndex(uint64(d)), len(h.counts)-1) //nolint:gosec
+	var n uint64
+	for _, c := range h.counts[:last+1] {
+		n += c
+	}
+
+	return int64(n) //nolint:gosec
+}
+
+// HistogramBucket is a non-empty [Low, High) value range of a Histogram.
+type HistogramBucket struct {
+	Low   time.Duration
+	High  time.Duration
+	Count int64
+}
+
+// Buckets returns non-empty buckets in ascending order.
+func (h *Histogram) Buckets() []HistogramBucket {
+	res := make([]HistogramBucket, 0)
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+
+		low, high := histBounds(i)
+		res = append(res, HistogramBucket{
+			Low:   time.Duration(low),  //nolint:gosec
+			High:  time.Duration(high), //nolint:gosec
+			Count: int64(c),            //nolint:gosec
+		})
+	}
+
+	return res
+}
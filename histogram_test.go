@@ -0,0 +1,81 @@
+package stinger
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	for i, tc := range []struct {
+		in  []time.Duration
+		q   float64
+		out time.Duration
+	}{
+		{nil, 0.5, 0},
+		{[]time.Duration{7}, 0.99, 7},
+		{[]time.Duration{1, 2, 3, 4}, 0.5, 2},
+		{[]time.Duration{1, 2, 3, 4}, 1, 4},
+		{[]time.Duration{1, 2, 3, 4}, 0, 1},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			h := NewHistogram()
+			for _, d := range tc.in {
+				h.Record(d)
+			}
+			assert.Equal(t, tc.out, h.Quantile(tc.q))
+		})
+	}
+}
+
+func TestHistogramPrecision(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	assert.Equal(t, int64(10000), h.Count())
+	assert.Equal(t, time.Microsecond, h.Min())
+	assert.Equal(t, 10*time.Millisecond, h.Max())
+	assert.InEpsilon(t, 5000*time.Microsecond, h.Mean(), 0.01)
+	assert.InEpsilon(t, 5*time.Millisecond, h.Quantile(0.5), 0.03)
+	assert.InEpsilon(t, 9900*time.Microsecond, h.Quantile(0.99), 0.03)
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(time.Second)
+	b.Record(time.Second)
+
+	a.Merge(b)
+	a.Merge(nil)
+
+	assert.Equal(t, int64(3), a.Count())
+	assert.Equal(t, time.Millisecond, a.Min())
+	assert.Equal(t, time.Second, a.Max())
+	assert.InEpsilon(t, time.Second, a.Quantile(0.5), 0.03)
+}
+
+func TestHistogramJSON(t *testing.T) {
+	h := NewHistogram()
+	h.RecordN(time.Millisecond, 3)
+	h.Record(time.Second)
+
+	b, err := json.Marshal(h)
+	assert.NoError(t, err)
+
+	res := NewHistogram()
+	assert.NoError(t, json.Unmarshal(b, res))
+	assert.Equal(t, h.Count(), res.Count())
+	assert.Equal(t, h.Sum(), res.Sum())
+	assert.Equal(t, h.Min(), res.Min())
+	assert.Equal(t, h.Max(), res.Max())
+	assert.Equal(t, h.Buckets(), res.Buckets())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"count":2,"buckets":[[1,1]]}`), res))
+	assert.Error(t, json.Unmarshal([]byte(`{"count":1,"buckets":[[100000,1]]}`), res))
+}
@@ -0,0 +1,311 @@
+package stinger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const historyIndex = "index.json"
+
+var historyUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// HistoryEntry is the summary of a saved run.
+type HistoryEntry struct {
+	Benchmark  string            `json:"benchmark"`
+	ID         string            `json:"id"`
+	Start      time.Time         `json:"start"`
+	Duration   time.Duration     `json:"duration"`
+	Requests   int64             `json:"requests"`
+	Errors     int64             `json:"errors"`
+	Throughput float64           `json:"throughput"`
+	P50        time.Duration     `json:"p50"`
+	P99        time.Duration     `json:"p99"`
+	Revision   string            `json:"revision,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	// File is the path of the saved result relative to the store.
+	File string `json:"file"`
+}
+
+// resultRecord is the saved part of a Result.
+type resultRecord struct {
+	Info          RunInfo       `json:"info"`
+	Duration      time.Duration `json:"duration"`
+	Requests      int64         `json:"requests"`
+	Responses     []Response    `json:"responses"`
+	SentBytes     uint64        `json:"sent_bytes"`
+	ReceivedBytes uint64        `json:"received_bytes"`
+	Success       *Histogram    `json:"success"`
+	Failure       *Histogram    `json:"failure"`
+	Checks        []CheckResult `json:"checks,omitempty"`
+	Errors        []ErrorGroup  `json:"errors,omitempty"`
+}
+
+func newResultRecord(r *Result) resultRecord {
+	return resultRecord{
+		Info:          r.info,
+		Duration:      r.duration,
+		Requests:      r.requests,
+		Responses:     r.responses,
+		SentBytes:     r.sentBytes,
+		ReceivedBytes: r.receivedBytes,
+		Success:       r.SuccessHistogram(),
+		Failure:       r.FailureHistogram(),
+		Checks:        r.checks,
+		Errors:        r.errors,
+	}
+}
+
+func (rec resultRecord) result() *Result {
+	l := newLatencies()
+	l.success.Merge(rec.Success)
+	l.failure.Merge(rec.Failure)
+
+	return &Result{
+		latency:       latencyPercentiles(l),
+		duration:      rec.Duration,
+		requests:      rec.Requests,
+		responses:     rec.Responses,
+		sentBytes:     rec.SentBytes,
+		receivedBytes: rec.ReceivedBytes,
+		traffic:       TrafficStats{SentBytes: rec.SentBytes, ReceivedBytes: rec.ReceivedBytes},
+		latencies:     l,
+		errors:        rec.Errors,
+		checks:        rec.Checks,
+		info:          rec.Info,
+	}
+}
+
+// History is a directory of saved results with an index of their summaries.
+// A single process may write to it at a time.
+type History struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenHistory opens the store in dir, creating it if needed.
+func OpenHistory(dir string) (*History, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	return &History{dir: dir}, nil
+}
+
+func (h *History) readIndex() ([]HistoryEntry, error) {
+	b, err := os.ReadFile(filepath.Join(h.dir, historyIndex))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: read index err: %w", err)
+	}
+
+	var entries []HistoryEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("history: parse index err: %w", err)
+	}
+
+	return entries, nil
+}
+
+// writeFile replaces the file at path atomically.
+func writeFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Save stores r under the benchmark name and returns its index entry. The
+// entry ID is the run start, so saving the same run again replaces it.
+func (h *History) Save(benchmark string, r *Result) (HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.readIndex()
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+
+	start := r.info.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	id := start.UTC().Format("20060102T150405.000000000Z")
+	e := HistoryEntry{
+		Benchmark:  benchmark,
+		ID:         id,
+		Start:      start,
+		Duration:   r.duration,
+		Requests:   r.requests,
+		Errors:     r.Errors(),
+		Throughput: r.Throughput(),
+		P50:        r.Percentile(50),
+		P99:        r.Percentile(99),
+		Revision:   r.info.Revision,
+		Labels:     r.info.Labels,
+		File:       filepath.Join(historyUnsafeChars.ReplaceAllString(benchmark, "_"), id+".json"),
+	}
+
+	if err := os.MkdirAll(filepath.Join(h.dir, filepath.Dir(e.File)), 0o755); err != nil {
+		return HistoryEntry{}, fmt.Errorf("history: %w", err)
+	}
+	if err := writeFile(filepath.Join(h.dir, e.File), newResultRecord(r)); err != nil {
+		return HistoryEntry{}, fmt.Errorf("history: write result err: %w", err)
+	}
+
+	i := slices.IndexFunc(entries, func(o HistoryEntry) bool { return o.Benchmark == e.Benchmark && o.ID == e.ID })
+	if i >= 0 {
+		entries[i] = e
+	} else {
+		entries = append(entries, e)
+	}
+	if err := writeFile(filepath.Join(h.dir, historyIndex), entries); err != nil {
+		return HistoryEntry{}, fmt.Errorf("history: write index err: %w", err)
+	}
+
+	return e, nil
+}
+
+// Entries returns entries of the benchmark, the oldest first.
+func (h *History) Entries(benchmark string) ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]HistoryEntry, 0)
+	for _, e := range entries {
+		if e.Benchmark == benchmark {
+			res = append(res, e)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+
+	return res, nil
+}
+
+// Load reads the saved result of the entry. Timeline, traffic details and
+// latency by code or tag are not saved.
+func (h *History) Load(e HistoryEntry) (*Result, error) {
+	b, err := os.ReadFile(filepath.Join(h.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	var rec resultRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("history: parse result err: %w", err)
+	}
+
+	return rec.result(), nil
+}
+
+// TrendReport is how a benchmark evolved over its last runs.
+type TrendReport struct {
+	Benchmark string
+	Entries   []HistoryEntry
+	// ThroughputChange and P99Change are relative changes (e.g. -0.05 for
+	// -5%) of a linear fit from the first to the last run. A fit follows a
+	// slow drift, while run to run noise cancels out.
+	ThroughputChange float64
+	P99Change        float64
+}
+
+// Trend returns the report on the last n runs of the benchmark, all if n <= 0.
+func (h *History) Trend(benchmark string, n int) (*TrendReport, error) {
+	entries, err := h.Entries(benchmark)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	throughput := make([]float64, len(entries))
+	p99 := make([]float64, len(entries))
+	for i, e := range entries {
+		throughput[i] = e.Throughput
+		p99[i] = float64(e.P99)
+	}
+
+	return &TrendReport{
+		Benchmark:        benchmark,
+		Entries:          entries,
+		ThroughputChange: fitChange(throughput),
+		P99Change:        fitChange(p99),
+	}, nil
+}
+
+// fitChange fits values with a least squares line and returns its relative
+// change from the first to the last value.
+func fitChange(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range values {
+		x := float64(i)
+		sumX += x
+		sumY += v
+		sumXY += x * v
+		sumXX += x * x
+	}
+
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	first := (sumY - slope*sumX) / n
+	last := first + slope*(n-1)
+	if first == 0 {
+		return 0
+	}
+
+	return (last - first) / first
+}
+
+// Print writes the report to stdout.
+func (t *TrendReport) Print() {
+	fmt.Printf("\nTREND: %s, last %d runs\n", t.Benchmark, len(t.Entries))
+	for _, e := range t.Entries {
+		fmt.Printf("%s %-12s %10.2f req/s p(99) %-12s errors %d\n",
+			e.Start.Format(time.DateTime), e.Revision, e.Throughput, e.P99, e.Errors)
+	}
+
+	if len(t.Entries) < 2 {
+		return
+	}
+
+	throughput := make([]float64, len(t.Entries))
+	p99 := make([]float64, len(t.Entries))
+	for i, e := range t.Entries {
+		throughput[i] = e.Throughput
+		p99[i] = float64(e.P99)
+	}
+
+	fmt.Printf("throughput .................... %s %+0.2f%%\n", sparkValues(throughput), t.ThroughputChange*100)
+	fmt.Printf("p(99) ......................... %s %+0.2f%%\n", sparkValues(p99), t.P99Change*100)
+}
@@ -0,0 +1,103 @@
+package stinger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func historyResult(start time.Time, requests int64, latency time.Duration) *Result {
+	recorded := newLatencies()
+	for range requests {
+		recorded.record(latency, "OK", true, "", nil)
+	}
+	l := newLatencies()
+	l.merge(recorded)
+
+	return &Result{
+		latency:   latencyPercentiles(l),
+		duration:  time.Second,
+		requests:  requests,
+		responses: []Response{{Code: "OK", Success: true, Count: requests}},
+		latencies: l,
+		info:      RunInfo{Start: start, Revision: "abc123", Labels: map[string]string{"env": "ci"}},
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenHistory(dir)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		_, err := h.Save("unary/hello", historyResult(start.Add(time.Duration(4-i)*time.Hour), int64(100-10*i), time.Duration(i+1)*time.Millisecond))
+		require.NoError(t, err)
+	}
+	_, err = h.Save("other", historyResult(start, 1, time.Millisecond))
+	require.NoError(t, err)
+
+	// NOTE: the store is reopened to read the index from disk
+	h, err = OpenHistory(dir)
+	require.NoError(t, err)
+
+	entries, err := h.Entries("unary/hello")
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+	assert.Equal(t, start, entries[0].Start.UTC())
+	assert.Equal(t, 5*time.Millisecond, entries[0].P99)
+	assert.InDelta(t, 60, entries[0].Throughput, 0.001)
+	assert.Equal(t, "abc123", entries[0].Revision)
+	assert.Equal(t, map[string]string{"env": "ci"}, entries[0].Labels)
+	assert.Equal(t, filepath.Join("unary_hello", "20240101T000000.000000000Z.json"), entries[0].File)
+	assert.FileExists(t, filepath.Join(dir, entries[0].File))
+
+	r, err := h.Load(entries[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), r.Requests())
+	assert.Equal(t, int64(60), r.SuccessHistogram().Count())
+	assert.Equal(t, 5*time.Millisecond, r.Percentile(99))
+	assert.Equal(t, "abc123", r.RunInfo().Revision)
+
+	trend, err := h.Trend("unary/hello", 3)
+	require.NoError(t, err)
+	require.Len(t, trend.Entries, 3)
+	assert.Equal(t, entries[2:], trend.Entries)
+	assert.InDelta(t, (100-80)/80.0, trend.ThroughputChange, 0.001)
+	assert.InDelta(t, (1-3)/3.0, trend.P99Change, 0.001)
+
+	_, err = os.Stat(filepath.Join(dir, historyIndex+".tmp"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestHistorySaveTwice(t *testing.T) {
+	dir := t.TempDir()
+	h, err := OpenHistory(dir)
+	require.NoError(t, err)
+
+	r := historyResult(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 10, time.Millisecond)
+	_, err = h.Save("unary", r)
+	require.NoError(t, err)
+	e, err := h.Save("unary", r)
+	require.NoError(t, err)
+
+	entries, err := h.Entries("unary")
+	require.NoError(t, err)
+	assert.Equal(t, []HistoryEntry{e}, entries, "saving the same run again must replace its entry")
+
+	b, err := os.ReadFile(filepath.Join(dir, e.File))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"revision": "abc123"`)
+}
+
+func TestFitChange(t *testing.T) {
+	assert.Zero(t, fitChange(nil))
+	assert.Zero(t, fitChange([]float64{10}))
+	assert.InDelta(t, 0.5, fitChange([]float64{10, 12.5, 15}), 0.001)
+	// NOTE: a fit smooths noise out
+	assert.InDelta(t, 0, fitChange([]float64{10, 12, 8, 8, 12, 10}), 0.001)
+}
@@ -0,0 +1,196 @@
+package stinger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type InfluxConfig struct {
+	// Path of a file to append lines to. Takes precedence over URL.
+	Path string
+	// URL of the write endpoint with nanosecond precision,
+	// e.g. http://localhost:8086/api/v2/write?org=o&bucket=b
+	URL   string
+	Token string
+	// Measurement is "stinger" if empty.
+	Measurement string
+	Tags        map[string]string
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// InfluxOutput writes intervals in InfluxDB line protocol. Plain values go to
+// a single line of the measurement, labeled ones (e.g. responses by code) to
+// lines of the measurement_name measurement. Run labels and the revision are
+// tags of every line.
+type InfluxOutput struct {
+	cfg  InfluxConfig
+	tags []label
+
+	mu *sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+func NewInfluxOutput(cfg InfluxConfig) (*InfluxOutput, error) {
+	if cfg.Measurement == "" {
+		cfg.Measurement = "stinger"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	o := &InfluxOutput{cfg: cfg, tags: sortedLabels(cfg.Tags), mu: &sync.Mutex{}}
+
+	if cfg.Path != "" {
+		f, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("influx: open err: %w", err)
+		}
+
+		o.f = f
+		o.w = bufio.NewWriter(f)
+	}
+
+	return o, nil
+}
+
+func sortedLabels(m map[string]string) []label {
+	res := make([]label, 0, len(m))
+	for k, v := range m {
+		res = append(res, label{k, v})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+
+	return res
+}
+
+// setRunLabels adds run labels to the tags, configured tags win.
+func (o *InfluxOutput) setRunLabels(labels []label) {
+	o.tags = mergeLabels(labels, sortedLabels(o.cfg.Tags))
+}
+
+func (o *InfluxOutput) WriteInterval(ctx context.Context, i Interval) error {
+	b := o.lines(i)
+
+	if o.w != nil {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if _, err := o.w.Write(b); err != nil {
+			return fmt.Errorf("influx: write err: %w", err)
+		}
+
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("influx: new request err: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if o.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+o.cfg.Token)
+	}
+
+	resp, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("influx: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("influx: unexpected status %d: %s", resp.StatusCode, msg)
+	}
+
+	return nil
+}
+
+func (o *InfluxOutput) lines(i Interval) []byte {
+	var b []byte
+	ts := strconv.FormatInt(i.Start.UnixNano(), 10)
+
+	field := func(b []byte, name string, p point) []byte {
+		b = append(b, influxTagEscaper.Replace(name)...)
+		b = append(b, '=')
+
+		// NOTE: user-defined counters may be fractional, so counters are floats too
+		return strconv.AppendFloat(b, p.value, 'f', -1, 64)
+	}
+
+	line := func(b []byte, measurement string, labels []label) []byte {
+		b = append(b, influxMeasurementEscaper.Replace(measurement)...)
+		for _, l := range mergeLabels(o.tags, labels) {
+			b = append(b, ',')
+			b = append(b, influxTagEscaper.Replace(l.name)...)
+			b = append(b, '=')
+			b = append(b, influxTagEscaper.Replace(l.value)...)
+		}
+
+		return append(b, ' ')
+	}
+
+	b = line(b, o.cfg.Measurement, nil)
+	plain := 0
+	labeled := make([]point, 0)
+	for _, p := range intervalPoints(i) {
+		if len(p.labels) > 0 {
+			labeled = append(labeled, p)
+
+			continue
+		}
+
+		if plain > 0 {
+			b = append(b, ',')
+		}
+		b = field(b, p.name, p)
+		plain++
+	}
+	b = append(b, ' ')
+	b = append(b, ts...)
+	b = append(b, '\n')
+
+	for _, p := range labeled {
+		b = line(b, o.cfg.Measurement+"_"+p.name, p.labels)
+		b = field(b, "value", p)
+		b = append(b, ' ')
+		b = append(b, ts...)
+		b = append(b, '\n')
+	}
+
+	return b
+}
+
+func (o *InfluxOutput) Close() error {
+	if o.f == nil {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.w.Flush(); err != nil {
+		o.f.Close()
+
+		return fmt.Errorf("influx: flush err: %w", err)
+	}
+
+	return o.f.Close()
+}
@@ -0,0 +1,205 @@
+package stinger
+
+import "time"
+
+// Tag is a key/value pair attached to observed requests.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// ScenarioTag is the key of the tag every actor request carries, its value is the runner name.
+const ScenarioTag = "scenario"
+
+type requestInfo struct {
+	tags    []Tag
+	checks  []check
+	target  string
+	payload string
+}
+
+// RequestOption adds details to a request observed by ObserveRequest.
+type RequestOption func(*requestInfo)
+
+// WithTags tags the request, Result latency can be queried per tag.
+func WithTags(tags ...Tag) RequestOption {
+	return func(r *requestInfo) {
+		r.tags = append(r.tags, tags...)
+	}
+}
+
+// latencies are run-wide latency distributions. They are not safe for concurrent use.
+//
+// Recording takes a single histogram per response key, success, failure
+// and codes are derived from them by merge. So is the scenario tag while
+// all requests have the same scenario, as they do in a shard.
+type latencies struct {
+	success *Histogram
+	failure *Histogram
+	tags    map[Tag]*Histogram
+	codes   map[string]*Histogram
+	phases  map[Phase]*Histogram
+	// failedTags are the unsuccessful part of tags.
+	failedTags map[Tag]*Histogram
+
+	// responses are recorded distributions by response key, the last one is
+	// reached without the map.
+	responses    map[responseKey]*Histogram
+	last         responseKey
+	lastResponse *Histogram
+
+	// scenario is the scenario of all recorded requests till mixed.
+	scenario     string
+	mixed        bool
+	scenarioHist *Histogram
+}
+
+func newLatencies() *latencies {
+	return &latencies{
+		success: NewHistogram(),
+		failure: NewHistogram(),
+		tags:    make(map[Tag]*Histogram),
+		codes:   make(map[string]*Histogram),
+		phases:  make(map[Phase]*Histogram),
+
+		failedTags: make(map[Tag]*Histogram),
+		responses:  make(map[responseKey]*Histogram),
+	}
+}
+
+func (l *latencies) tag(t Tag) *Histogram {
+	h, ok := l.tags[t]
+	if !ok {
+		h = NewHistogram()
+		l.tags[t] = h
+	}
+
+	return h
+}
+
+func (l *latencies) failedTag(t Tag) *Histogram {
+	h, ok := l.failedTags[t]
+	if !ok {
+		h = NewHistogram()
+		l.failedTags[t] = h
+	}
+
+	return h
+}
+
+func (l *latencies) code(c string) *Histogram {
+	h, ok := l.codes[c]
+	if !ok {
+		h = NewHistogram()
+		l.codes[c] = h
+	}
+
+	return h
+}
+
+func (l *latencies) record(d time.Duration, code string, success bool, scenario string, tags []Tag) {
+	if !l.mixed && scenario != l.scenario {
+		if len(l.responses) == 0 {
+			l.scenario = scenario
+		} else {
+			l.mix()
+		}
+	}
+
+	key := responseKey{code, success}
+	if l.lastResponse == nil || key != l.last {
+		h, ok := l.responses[key]
+		if !ok {
+			h = NewHistogram()
+			l.responses[key] = h
+		}
+		l.last, l.lastResponse = key, h
+	}
+	l.lastResponse.Record(d)
+
+	if l.mixed && scenario != "" {
+		if scenario != l.scenario || l.scenarioHist == nil {
+			l.scenario, l.scenarioHist = scenario, l.tag(Tag{ScenarioTag, scenario})
+		}
+		l.scenarioHist.Record(d)
+		if !success {
+			l.failedTag(Tag{ScenarioTag, scenario}).Record(d)
+		}
+	}
+
+	for _, t := range tags {
+		l.tag(t).Record(d)
+	}
+	if !success {
+		for _, t := range tags {
+			l.failedTag(t).Record(d)
+		}
+	}
+}
+
+// mix records scenario tags from now on, the ones of the single scenario so
+// far are derived from responses.
+func (l *latencies) mix() {
+	if l.scenario != "" {
+		l.mergeScenario(l.scenario, l.responses)
+	}
+	l.mixed, l.scenarioHist = true, nil
+}
+
+func (l *latencies) mergeScenario(scenario string, responses map[responseKey]*Histogram) {
+	t := Tag{ScenarioTag, scenario}
+	for k, h := range responses {
+		l.tag(t).Merge(h)
+		if !k.success {
+			l.failedTag(t).Merge(h)
+		}
+	}
+}
+
+// all returns the distribution of all responses.
+func (l *latencies) all() *Histogram {
+	h := NewHistogram()
+	h.Merge(l.success)
+	h.Merge(l.failure)
+
+	return h
+}
+
+func (l *latencies) phase(p Phase) *Histogram {
+	h, ok := l.phases[p]
+	if !ok {
+		h = NewHistogram()
+		l.phases[p] = h
+	}
+
+	return h
+}
+
+// merge adds distributions recorded by another shard.
+func (l *latencies) merge(o *latencies) {
+	l.success.Merge(o.success)
+	l.failure.Merge(o.failure)
+	for k, h := range o.responses {
+		if k.success {
+			l.success.Merge(h)
+		} else {
+			l.failure.Merge(h)
+		}
+		l.code(k.code).Merge(h)
+	}
+	if !o.mixed && o.scenario != "" {
+		l.mergeScenario(o.scenario, o.responses)
+	}
+	for t, h := range o.tags {
+		l.tag(t).Merge(h)
+	}
+	for c, h := range o.codes {
+		l.code(c).Merge(h)
+	}
+	for p, h := range o.phases {
+		l.phase(p).Merge(h)
+	}
+	for t, h := range o.failedTags {
+		l.failedTag(t).Merge(h)
+	}
+}
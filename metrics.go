@@ -1,15 +1,15 @@
 package stinger
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	dto "github.com/prometheus/client_model/go"
 )
 
 type LatencyPercentile struct {
@@ -24,309 +24,375 @@ type Response struct {
 	Count   int64
 }
 
+// Metrics is a handle to the benchmark metrics. Benchmark hands every actor
+// its own handle sharing the same state, so observations carry the actor ID.
 type Metrics struct {
-	// NOTE: enabled introduced as a hack for not observing traffic before test start
-	enabled       bool
-	latency       *prometheus.SummaryVec
-	requests      prometheus.Counter
-	responses     *prometheus.CounterVec
-	sentBytes     prometheus.Gauge
-	receivedBytes prometheus.Gauge
+	*metricsState
+
+	actor    int
+	scenario string
+	bytes    *byteCounter
+	// shard records observations of the handle.
+	shard *shard
+	// runner counts traffic of the actor runner, nil outside actors.
+	runner *traffic
+}
+
+// byteCounter tracks traffic of a single actor. A nil counter discards everything.
+type byteCounter struct {
+	sent     atomic.Uint64
+	received atomic.Uint64
+}
+
+func (c *byteCounter) add(sent, received uint64) {
+	if c == nil {
+		return
+	}
+
+	c.sent.Add(sent)
+	c.received.Add(received)
+}
+
+func (c *byteCounter) load() (uint64, uint64) {
+	if c == nil {
+		return 0, 0
+	}
+
+	return c.sent.Load(), c.received.Load()
+}
+
+type metricsState struct {
+	gatherer prometheus.Gatherer
+	recorder *recorder
+	runtime  *runtimeMonitor
+	scraper  *targetScraper
+	profiler *profiler
+	errors   *errorGroups
+	checks   *checks
+	quality  Quality
+	info     RunInfo
+	slowest  *slowest
+	traffic  *trafficStats
+	samples  SampleSink
+	// customKinds maps user-defined metric names to their kinds.
+	customKinds sync.Map
+	// rejectedMetrics counts user-defined metric values rejected since StartTimer.
+	rejectedMetrics atomic.Uint64
 
 	start    time.Time
 	duration time.Duration
 }
 
 func NewMetrics() *Metrics {
-	m := new(Metrics)
+	return newMetrics(prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+}
 
-	m.latency = promauto.NewSummaryVec(prometheus.SummaryOpts{
-		Name:       "latency",
-		Help:       "request latency",
-		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.95: 0.005, 0.99: 0.001},
-	}, []string{"success"})
+// newIsolatedMetrics returns metrics registered in their own registry.
+func newIsolatedMetrics() *Metrics {
+	reg := prometheus.NewRegistry()
 
-	m.requests = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "requests_total",
-		Help: "total requests number (grpc/iproto)",
-	})
+	return newMetrics(reg, reg)
+}
+
+func newMetrics(reg prometheus.Registerer, g prometheus.Gatherer) *Metrics {
+	m := &Metrics{metricsState: new(metricsState), actor: -1}
+	m.gatherer = g
+	factory := promauto.With(reg)
+
+	m.recorder = newRecorder(DefaultInterval)
+	m.shard = m.recorder.newShard()
+	reg.MustRegister(newMetricsCollector(m.metricsState))
 
-	m.responses = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "responses_total",
-		Help: "total response number (grpc/iproto)",
-	}, []string{"code", "success"})
+	m.traffic = newTrafficStats()
 
-	m.sentBytes = promauto.NewGauge(prometheus.GaugeOpts{
+	factory.NewCounterFunc(prometheus.CounterOpts{
 		Name: "sent_bytes",
 		Help: "sent bytes from client to service",
+	}, func() float64 {
+		return float64(m.SentBytes())
 	})
 
-	m.receivedBytes = promauto.NewGauge(prometheus.GaugeOpts{
+	factory.NewCounterFunc(prometheus.CounterOpts{
 		Name: "received_bytes",
 		Help: "received bytes from client to service",
+	}, func() float64 {
+		return float64(m.ReceivedBytes())
 	})
 
+	m.runtime = newRuntimeMonitor()
+	m.scraper = newTargetScraper()
+	m.profiler = &profiler{}
+	m.errors = newErrorGroups()
+	m.checks = newChecks()
+	m.slowest = newSlowest(DefaultSlowest)
+	reg.MustRegister(&customCollector{m.recorder})
+	m.info = newRunInfo()
+	reg.MustRegister(&runInfoCollector{m})
+
 	return m
 }
 
-func (m *Metrics) Enable() {
-	m.enabled = true
+// forActor returns a handle attributing observations to the given actor.
+func (m *Metrics) forActor(id int, scenario string) *Metrics {
+	return &Metrics{
+		metricsState: m.metricsState,
+		actor:        id,
+		scenario:     scenario,
+		shard:        m.recorder.newShard(),
+		bytes:        new(byteCounter),
+		runner:       m.traffic.runner(scenario),
+	}
+}
+
+// SetSampleSink makes every observed request to be written to s.
+func (m *Metrics) SetSampleSink(s SampleSink) {
+	m.samples = s
 }
 
-func (m *Metrics) Disable() {
-	m.enabled = false
+// Enable does nothing.
+//
+// Deprecated: traffic is counted since StartTimer.
+func (m *Metrics) Enable() {}
+
+// Disable does nothing.
+//
+// Deprecated: traffic is counted since StartTimer.
+func (m *Metrics) Disable() {}
+
+// SetInterval sets the width of the timeline intervals. Must be called before StartTimer.
+func (m *Metrics) SetInterval(d time.Duration) {
+	if d > 0 {
+		m.recorder.setInterval(d)
+	}
 }
 
+// StartTimer starts the run, traffic observed before is dropped.
 func (m *Metrics) StartTimer() {
 	m.start = time.Now()
+	m.traffic.reset()
+	m.rejectedMetrics.Store(0)
+	m.recorder.reset(m.start)
 }
 
 func (m *Metrics) StopTimer() {
 	m.duration = time.Since(m.start)
+	m.recorder.stop(m.start.Add(m.duration))
 }
 
 func (m *Metrics) Requests() int64 {
-	var metric dto.Metric
-
-	err := m.requests.Write(&metric)
-	if err != nil {
-		panic(err)
-	}
-
-	return int64(metric.GetCounter().GetValue())
+	return m.recorder.snapshot().requests
 }
 
 func (m *Metrics) IncReq(i int64) {
-	m.requests.Add(float64(i))
+	m.shard.addRequests(time.Now(), i)
 }
 
-func (m *Metrics) ObserveRequest(f func() (string, bool, error)) error {
-	s := time.Now()
-	m.IncReq(1)
-	code, success, err := f()
-	m.latency.WithLabelValues(strconv.FormatBool(success)).Observe(float64(time.Since(s).Nanoseconds()))
-	m.IncResponses(code, success, 1)
+// replay records a previously logged sample as if it was observed live.
+func (m *Metrics) replay(s Sample) {
+	m.shard.replay(s)
 
-	return err
+	for _, t := range []*traffic{m.traffic.total, m.traffic.runner(s.Scenario)} {
+		t.sent.Add(s.SentBytes)
+		t.received.Add(s.ReceivedBytes)
+	}
 }
 
 func (m *Metrics) SentBytes() uint64 {
-	var metric dto.Metric
-
-	err := m.sentBytes.Write(&metric)
-	if err != nil {
-		panic(err)
-	}
-
-	return uint64(metric.GetGauge().GetValue())
+	return m.traffic.total.sent.Load()
 }
 
+// AddSentBytes counts bytes sent by the actor outside of observed connections.
 func (m *Metrics) AddSentBytes(i uint64) {
-	if m.enabled {
-		m.sentBytes.Add(float64(i))
-	}
+	m.addTraffic(i, 0, m.ownTraffic()...)
 }
 
 func (m *Metrics) ReceivedBytes() uint64 {
-	var metric dto.Metric
-
-	err := m.receivedBytes.Write(&metric)
-	if err != nil {
-		panic(err)
-	}
-
-	return uint64(metric.GetGauge().GetValue())
+	return m.traffic.total.received.Load()
 }
 
+// AddReceivedBytes counts bytes received by the actor outside of observed connections.
 func (m *Metrics) AddReceivedBytes(i uint64) {
-	if m.enabled {
-		m.receivedBytes.Add(float64(i))
-	}
+	m.addTraffic(0, i, m.ownTraffic()...)
 }
 
+// Latency returns latency percentiles of successful and failed requests. The
+// error is always nil.
 func (m *Metrics) Latency() ([]LatencyPercentile, error) {
-	ch := make(chan prometheus.Metric)
-	go func() {
-		m.latency.Collect(ch)
-		close(ch)
-	}()
+	return latencyPercentiles(m.recorder.snapshot().latencies), nil
+}
 
+// latencyPercentiles returns the reported percentiles of non-empty distributions.
+func latencyPercentiles(l *latencies) []LatencyPercentile {
 	res := make([]LatencyPercentile, 0)
-	for m := range ch {
-		var metric dto.Metric
-
-		err := m.Write(&metric)
-		if err != nil {
-			return nil, fmt.Errorf("get latency err: %w", err)
+	for _, s := range []struct {
+		success bool
+		h       *Histogram
+	}{{true, l.success}, {false, l.failure}} {
+		if s.h.Count() == 0 {
+			continue
 		}
-		summary := metric.GetSummary()
-
-		if summary.GetSampleSum() > 0 {
-			quantiles := summary.GetQuantile()
-
-			var success bool
-			var err error
-			for _, l := range metric.Label {
-				switch l.GetName() {
-				case "success":
-					success, err = strconv.ParseBool(l.GetValue())
-					if err != nil {
-						return nil, fmt.Errorf("get latency err: %w", err)
-					}
-				default:
-				}
-			}
-
-			for _, q := range quantiles {
-				v := time.Duration(q.GetValue())
-				res = append(res, LatencyPercentile{
-					Success:    success,
-					Percentile: int(q.GetQuantile() * 100),
-					Value:      v,
-				})
-			}
+
+		for _, p := range latencyQuantiles {
+			res = append(res, LatencyPercentile{
+				Success:    s.success,
+				Percentile: int(p * 100),
+				Value:      s.h.Quantile(p),
+			})
 		}
 	}
 
-	return res, nil
+	return res
 }
 
 func (m *Metrics) IncResponses(code string, success bool, i int64) {
-	m.responses.WithLabelValues(code, strconv.FormatBool(success)).Add(float64(i))
+	m.shard.addResponses(time.Now(), code, success, i)
 }
 
 func (m *Metrics) Responses() []Response {
-	ch := make(chan prometheus.Metric)
-	go func() {
-		m.responses.Collect(ch)
-		close(ch)
-	}()
-
-	res := make([]Response, 0)
-	for m := range ch {
-		var metric dto.Metric
-
-		err := m.Write(&metric)
-		if err != nil {
-			panic(err)
-		}
+	return sortedResponses(m.recorder.snapshot().responses)
+}
 
-		resp := Response{}
-		resp.Count = int64(metric.GetCounter().GetValue())
-
-		for _, l := range metric.Label {
-			switch l.GetName() {
-			case "code":
-				resp.Code = l.GetValue()
-			case "success":
-				b, err := strconv.ParseBool(l.GetValue())
-				if err != nil {
-					panic(fmt.Errorf("strconv parse bool err: %s", err))
-				}
-				resp.Success = b
-			}
-		}
+func (m *Metrics) Result() *Result {
+	snap := m.recorder.snapshot()
+	total, targets, runners := m.traffic.snapshot()
+	scraped, scrapeFailures := m.scraper.snapshot()
 
-		res = append(res, resp)
+	var droppedSamples uint64
+	if d, ok := m.samples.(droppingSink); ok {
+		droppedSamples = d.Dropped()
 	}
 
-	return res
+	return &Result{
+		latency:         latencyPercentiles(snap.latencies),
+		requests:        snap.requests,
+		responses:       sortedResponses(snap.responses),
+		duration:        m.duration,
+		sentBytes:       total.SentBytes,
+		receivedBytes:   total.ReceivedBytes,
+		traffic:         total,
+		targets:         targets,
+		runners:         runners,
+		timeline:        m.Timeline(),
+		latencies:       snap.latencies,
+		runtime:         m.runtime.snapshot(),
+		scraped:         scraped,
+		scrapeFailures:  scrapeFailures,
+		profiles:        m.profiler.snapshot(),
+		errors:          m.errors.snapshot(),
+		custom:          snap.custom,
+		quality:         m.quality,
+		info:            m.RunInfo(),
+		checks:          snap.checks,
+		slowest:         m.slowest.snapshot(),
+		droppedSamples:  droppedSamples,
+		rejectedMetrics: m.rejectedMetrics.Load(),
+	}
 }
 
-func (m *Metrics) Result() *Result {
-	latency, err := m.Latency()
-	if err != nil {
-		panic(err)
-	}
+// Timeline returns per-interval metrics recorded since StartTimer.
+func (m *Metrics) Timeline() []Interval {
+	return m.TimelineFrom(0)
+}
 
-	return &Result{
-		latency:       latency,
-		requests:      m.Requests(),
-		responses:     m.Responses(),
-		duration:      m.duration,
-		sentBytes:     m.SentBytes(),
-		receivedBytes: m.ReceivedBytes(),
+// TimelineFrom returns per-interval metrics starting from the interval i.
+func (m *Metrics) TimelineFrom(i int) []Interval {
+	res := m.recorder.snapshotFrom(i)
+	for j := range res {
+		res[j].Quality = newScore("", res[j].Latency, res[j].failures, m.quality)
 	}
+
+	samples, _ := m.scraper.snapshot()
+	targetValues(res, samples)
+
+	return res
 }
 
-type Result struct {
-	latency       []LatencyPercentile
-	duration      time.Duration
-	requests      int64
-	responses     []Response
-	sentBytes     uint64
-	receivedBytes uint64
+// latencyQuantiles are latency quantiles reported by Latency and exported.
+var latencyQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// metricsCollector exposes the merged request metrics in the Prometheus registry.
+type metricsCollector struct {
+	s *metricsState
+
+	requests  *prometheus.Desc
+	responses *prometheus.Desc
+	latency   *prometheus.Desc
+	inflight  *prometheus.Desc
+	apdex     *prometheus.Desc
+	slo       *prometheus.Desc
+	checks    *prometheus.Desc
 }
 
-func getSpacer(s string, l int) string {
-	b := make([]byte, l)
-	for i := range l {
-		b[i] = '.'
+func newMetricsCollector(s *metricsState) *metricsCollector {
+	return &metricsCollector{
+		s:         s,
+		requests:  prometheus.NewDesc("requests_total", "total requests number (grpc/iproto)", nil, nil),
+		responses: prometheus.NewDesc("responses_total", "total response number (grpc/iproto)", []string{"code", "success"}, nil),
+		latency:   prometheus.NewDesc("latency", "request latency", []string{"success"}, nil),
+		inflight:  prometheus.NewDesc("inflight_requests", "number of outstanding requests", nil, nil),
+		apdex:     prometheus.NewDesc("apdex", "apdex score, operation is empty for all requests", []string{"operation"}, nil),
+		slo:       prometheus.NewDesc("slo_compliance", "share of successful responses within the latency target", []string{"operation"}, nil),
+		checks:    prometheus.NewDesc("checks_total", "total checks number by outcome", []string{"check", "pass"}, nil),
 	}
+}
 
-	return string(b[len(s):])
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.responses
+	ch <- c.latency
+	ch <- c.inflight
+	ch <- c.apdex
+	ch <- c.slo
+	ch <- c.checks
 }
 
-func (r *Result) Print() {
-	fmt.Println("\nRESULTS:")
-	fmt.Printf("elapsed ....................... %s\n", r.duration)
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	snap := c.s.recorder.snapshot()
 
-	responsesCount := int64(0)
-	errorsCount := int64(0)
-	for _, r := range r.responses {
-		responsesCount += r.Count
-		if !r.Success {
-			errorsCount += r.Count
-		}
+	ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(snap.requests))
+	for k, n := range snap.responses {
+		ch <- prometheus.MustNewConstMetric(c.responses, prometheus.CounterValue, float64(n), k.code, strconv.FormatBool(k.success))
 	}
 
-	if r.requests > 0 {
-		fmt.Println("\nREQUESTS:")
-		fmt.Printf("responses ..................... %d\n", responsesCount)
-		fmt.Printf("errors ........................ %d\n", errorsCount)
-		fmt.Printf("total ......................... %d\n", r.requests)
-		fmt.Printf("throughput .................... %0.2f %s\n", float64(r.requests)/r.duration.Seconds(), "req/s")
-
-		failedRequests := make([]LatencyPercentile, 0)
-		successedRequests := make([]LatencyPercentile, 0)
-		for _, r := range r.latency {
-			if r.Success {
-				successedRequests = append(successedRequests, r)
-			} else {
-				failedRequests = append(failedRequests, r)
-			}
+	for success, h := range map[bool]*Histogram{true: snap.latencies.success, false: snap.latencies.failure} {
+		if h.Count() == 0 {
+			continue
 		}
 
-		if len(successedRequests) > 0 {
-			fmt.Printf("SUCCESSED:\n")
-			for _, p := range successedRequests {
-				fmt.Printf("  latency p(%d) ................. %s\n", p.Percentile, p.Value)
-			}
+		quantiles := make(map[float64]float64, len(latencyQuantiles))
+		for _, q := range latencyQuantiles {
+			quantiles[q] = float64(h.Quantile(q))
 		}
+		//nolint:gosec
+		ch <- prometheus.MustNewConstSummary(c.latency, uint64(h.Count()), float64(h.Sum()), quantiles, strconv.FormatBool(success))
+	}
 
-		if len(failedRequests) > 0 {
-			fmt.Printf("FAILED:\n")
-			for _, p := range failedRequests {
-				fmt.Printf("  latency p(%d) ................. %s\n", p.Percentile, p.Value)
-			}
+	ch <- prometheus.MustNewConstMetric(c.inflight, prometheus.GaugeValue, float64(c.s.recorder.inflight()))
+
+	for _, s := range qualityScores(snap.latencies, c.s.quality) {
+		if s.Total == 0 {
+			continue
 		}
-	}
 
-	fmt.Println("\nCODES:")
-	for _, r := range r.responses {
-		fmt.Printf("%s %s %d\n", r.Code, getSpacer(r.Code, 30), r.Count)
+		ch <- prometheus.MustNewConstMetric(c.apdex, prometheus.GaugeValue, s.Apdex(), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.slo, prometheus.GaugeValue, s.Compliance(), s.Name)
 	}
 
-	data := r.receivedBytes + r.sentBytes
-	if data > 0 {
-		fmt.Println("\nDATA:")
-		fmt.Printf("sent .......................... %s\n", ByteCountIEC(r.sentBytes))
-		fmt.Printf("received ...................... %s\n", ByteCountIEC(r.receivedBytes))
-		fmt.Printf("total ......................... %s\n", ByteCountIEC(data))
-		fmt.Printf("throughput .................... %s/s\n", ByteCountIEC(uint64(float64(data)/r.duration.Seconds())))
+	for _, r := range snap.checks {
+		if r.Passes > 0 {
+			sendConst(ch)(prometheus.NewConstMetric(c.checks, prometheus.CounterValue, float64(r.Passes), r.Name, "true"))
+		}
+		if r.Fails > 0 {
+			sendConst(ch)(prometheus.NewConstMetric(c.checks, prometheus.CounterValue, float64(r.Fails), r.Name, "false"))
+		}
 	}
 }
 
+// Gatherer returns the Prometheus registry the metrics are registered in.
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.gatherer
+}
+
 func (m *Metrics) Serve() {
 	http.Handle("/metrics", promhttp.Handler())
 }
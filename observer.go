@@ -0,0 +1,190 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stage is a step of the benchmark lifecycle.
+type Stage string
+
+const (
+	// StageSetUp is runners being set up, before the timer starts.
+	StageSetUp Stage = "setup"
+	// StageRun is actors sending requests.
+	StageRun Stage = "run"
+	// StageStop is actors finishing their requests once the duration elapsed
+	// or the run was aborted.
+	StageStop Stage = "stop"
+)
+
+// ActorError is an error returned by an actor run.
+type ActorError struct {
+	Actor    int
+	Scenario string
+	Err      error
+	// First marks the first error of its kind, the rest are only counted.
+	First bool
+}
+
+// Observer is notified of the benchmark lifecycle. Callbacks are called in
+// the order of observers, one at a time except OnActorError, and should
+// return quickly as they hold the run back.
+type Observer interface {
+	// OnStart is called with the metrics of the run before runners are set up.
+	OnStart(context.Context, *Metrics)
+	OnStageChange(context.Context, Stage)
+	// OnIntervalSnapshot receives timeline intervals completed since the
+	// previous call every interval. The last call, once the timer stopped,
+	// receives the rest of the timeline.
+	OnIntervalSnapshot(context.Context, []Interval)
+	// OnActorError is called by actors concurrently.
+	OnActorError(context.Context, ActorError)
+	// OnAbort is called when the run is cut short: the context of Benchmark
+	// is canceled, or an actor fails to set up and the process exits.
+	OnAbort(context.Context, error)
+	// OnFinish receives the result once the timer stopped.
+	OnFinish(context.Context, *Result)
+}
+
+// NopObserver does nothing, embed it to implement only some callbacks.
+type NopObserver struct{}
+
+func (NopObserver) OnStart(context.Context, *Metrics)              {}
+func (NopObserver) OnStageChange(context.Context, Stage)           {}
+func (NopObserver) OnIntervalSnapshot(context.Context, []Interval) {}
+func (NopObserver) OnActorError(context.Context, ActorError)       {}
+func (NopObserver) OnAbort(context.Context, error)                 {}
+func (NopObserver) OnFinish(context.Context, *Result)              {}
+
+// observerSet calls observers in order, serializing all callbacks but OnActorError.
+type observerSet struct {
+	mu   sync.Mutex
+	list []Observer
+}
+
+// newObserverSet returns built-in observers configured by cfg followed by cfg.Observers.
+func newObserverSet(cfg BenchmarkConfig) *observerSet {
+	list := make([]Observer, 0, len(cfg.Observers)+4)
+	if cfg.Verbose {
+		list = append(list, errorPrinter{})
+	}
+	if len(cfg.Exporters) > 0 {
+		list = append(list, &exportObserver{interval: cfg.ExportInterval, exporters: cfg.Exporters})
+	}
+	if len(cfg.Outputs) > 0 {
+		list = append(list, outputObserver{outputs: cfg.Outputs})
+	}
+	if cfg.Dashboard {
+		list = append(list, newDashboard(os.Stdout, cfg.Duration))
+	}
+
+	return &observerSet{list: append(list, cfg.Observers...)}
+}
+
+func (s *observerSet) each(f func(Observer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.list {
+		f(o)
+	}
+}
+
+func (s *observerSet) start(ctx context.Context, m *Metrics) {
+	s.each(func(o Observer) { o.OnStart(ctx, m) })
+}
+
+func (s *observerSet) stageChange(ctx context.Context, stage Stage) {
+	s.each(func(o Observer) { o.OnStageChange(ctx, stage) })
+}
+
+func (s *observerSet) intervalSnapshot(ctx context.Context, intervals []Interval) {
+	s.each(func(o Observer) { o.OnIntervalSnapshot(ctx, intervals) })
+}
+
+func (s *observerSet) actorError(ctx context.Context, e ActorError) {
+	for _, o := range s.list {
+		o.OnActorError(ctx, e)
+	}
+}
+
+func (s *observerSet) abort(ctx context.Context, err error) {
+	s.each(func(o Observer) { o.OnAbort(ctx, err) })
+}
+
+func (s *observerSet) finish(ctx context.Context, r *Result) {
+	s.each(func(o Observer) { o.OnFinish(ctx, r) })
+}
+
+// startSnapshots delivers complete intervals to observers every interval
+// until the run ctx is done, then announces StageStop, after OnAbort if the
+// parent ctx is done too. The returned func stops the loop and delivers the
+// rest of the timeline.
+func startSnapshots(parent, ctx context.Context, m *Metrics, obs *observerSet) func() {
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+	next := 0
+
+	stopped := false
+	stop := func() {
+		if stopped {
+			return
+		}
+		stopped = true
+
+		if err := context.Cause(parent); err != nil {
+			obs.abort(ctx, err)
+		}
+		obs.stageChange(ctx, StageStop)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(m.recorder.interval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				intervals := m.TimelineFrom(next)
+				// NOTE: the last interval is still being recorded
+				if len(intervals) > 0 {
+					intervals = intervals[:len(intervals)-1]
+				}
+				next += len(intervals)
+				obs.intervalSnapshot(ctx, intervals)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		stop()
+		obs.intervalSnapshot(finalContext(ctx), m.TimelineFrom(next))
+	}
+}
+
+// errorPrinter prints the first error of every kind, the rest are counted in Result.
+type errorPrinter struct {
+	NopObserver
+}
+
+func (errorPrinter) OnActorError(_ context.Context, e ActorError) {
+	if e.First {
+		fmt.Printf("run err: %s\n", e.Err)
+	}
+}
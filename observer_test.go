@@ -0,0 +1,107 @@
+package stinger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	mu        sync.Mutex
+	events    []string
+	intervals int
+	errors    []ActorError
+	result    *Result
+}
+
+func (o *recordingObserver) add(e string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, e)
+}
+
+func (o *recordingObserver) OnStart(context.Context, *Metrics) {
+	o.add("start")
+}
+
+func (o *recordingObserver) OnStageChange(_ context.Context, s Stage) {
+	o.add(string(s))
+}
+
+func (o *recordingObserver) OnIntervalSnapshot(_ context.Context, intervals []Interval) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.intervals += len(intervals)
+}
+
+func (o *recordingObserver) OnActorError(_ context.Context, e ActorError) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.errors = append(o.errors, e)
+}
+
+func (o *recordingObserver) OnAbort(_ context.Context, err error) {
+	o.add("abort: " + err.Error())
+}
+
+func (o *recordingObserver) OnFinish(_ context.Context, r *Result) {
+	o.add("finish")
+	o.result = r
+}
+
+type failingActor struct{}
+
+func (failingActor) Run(*Metrics) error {
+	time.Sleep(time.Millisecond)
+
+	return errors.New("connection refused")
+}
+
+type failingRunner struct{}
+
+func (failingRunner) SetUp(context.Context) {}
+func (failingRunner) Parallelism() int      { return 1 }
+
+func (failingRunner) ActorSetup(context.Context, int) (Actor, error) {
+	return failingActor{}, nil
+}
+
+func TestObserver(t *testing.T) {
+	o := &recordingObserver{}
+	r := Benchmark(context.Background(), newIsolatedMetrics(), BenchmarkConfig{
+		Duration:  50 * time.Millisecond,
+		Interval:  10 * time.Millisecond,
+		Observers: []Observer{o},
+	}, failingRunner{})
+
+	assert.Equal(t, []string{"start", "setup", "run", "stop", "finish"}, o.events)
+	assert.Same(t, r, o.result)
+	assert.Equal(t, len(r.Timeline()), o.intervals, "every interval once")
+
+	require.NotEmpty(t, o.errors)
+	assert.Equal(t, ActorError{0, "stinger.failingRunner", o.errors[0].Err, true}, o.errors[0])
+	for _, e := range o.errors[1:] {
+		assert.False(t, e.First)
+	}
+}
+
+func TestObserverAbort(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	o := &recordingObserver{}
+	Benchmark(ctx, newIsolatedMetrics(), BenchmarkConfig{
+		Duration:  time.Minute,
+		Observers: []Observer{o},
+	}, sleepRunner{})
+
+	assert.Equal(t, []string{"start", "setup", "run", "abort: context canceled", "stop", "finish"}, o.events)
+}
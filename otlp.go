@@ -0,0 +1,364 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+type OTLPProtocol string
+
+const (
+	OTLPHTTP OTLPProtocol = "http"
+	OTLPGRPC OTLPProtocol = "grpc"
+)
+
+// DefaultLatencyBuckets are the upper bounds of exported latency histograms.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+type OTLPConfig struct {
+	// Protocol is OTLPHTTP if empty.
+	Protocol OTLPProtocol
+	// Endpoint is either host:port or a URL, the exporter default if empty.
+	Endpoint string
+	Insecure bool
+	Headers  map[string]string
+	// Attributes are added to the resource, e.g. run id or environment.
+	// service.name is "stinger" unless set.
+	Attributes map[string]string
+	// Buckets are latency histogram bounds, DefaultLatencyBuckets if nil.
+	Buckets []time.Duration
+}
+
+// OTLPExporter sends cumulative request, response, latency and traffic
+// metrics to an OpenTelemetry collector.
+type OTLPExporter struct {
+	exporter sdkmetric.Exporter
+	resource *resource.Resource
+	buckets  []time.Duration
+}
+
+func NewOTLPExporter(ctx context.Context, cfg OTLPConfig) (*OTLPExporter, error) {
+	var exporter sdkmetric.Exporter
+	var err error
+
+	switch cfg.Protocol {
+	case OTLPHTTP, "":
+		opts := make([]otlpmetrichttp.Option, 0)
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlpmetrichttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlpmetrichttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetrichttp.WithHeaders(cfg.Headers))
+		}
+
+		exporter, err = otlpmetrichttp.New(ctx, opts...)
+	case OTLPGRPC:
+		opts := make([]otlpmetricgrpc.Option, 0)
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlpmetricgrpc.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(cfg.Headers))
+		}
+
+		exporter, err = otlpmetricgrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("otlp: unknown protocol %q", cfg.Protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("otlp: new exporter err: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", "stinger")}
+	for k, v := range cfg.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	buckets := cfg.Buckets
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+
+	return &OTLPExporter{
+		exporter: exporter,
+		// NOTE: later attributes win, so user attributes override service.name
+		resource: resource.NewSchemaless(attrs...),
+		buckets:  buckets,
+	}, nil
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, m *Metrics) error {
+	if err := e.exporter.Export(ctx, e.collect(m)); err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e *OTLPExporter) collect(m *Metrics) *metricdata.ResourceMetrics {
+	start, now := m.start, time.Now()
+	snap := m.recorder.snapshot()
+
+	sum := func(name, unit, description string, points ...metricdata.DataPoint[int64]) metricdata.Metrics {
+		for i := range points {
+			points[i].StartTime = start
+			points[i].Time = now
+		}
+
+		return metricdata.Metrics{
+			Name:        name,
+			Description: description,
+			Unit:        unit,
+			Data: metricdata.Sum[int64]{
+				DataPoints:  points,
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			},
+		}
+	}
+
+	responses := make([]metricdata.DataPoint[int64], 0)
+	for _, r := range sortedResponses(snap.responses) {
+		responses = append(responses, metricdata.DataPoint[int64]{
+			Attributes: attribute.NewSet(
+				attribute.String("code", r.Code),
+				attribute.String("success", strconv.FormatBool(r.Success)),
+			),
+			Value: r.Count,
+		})
+	}
+
+	//nolint:gosec
+	metrics := []metricdata.Metrics{
+		sum("stinger.requests", "{request}", "total requests number",
+			metricdata.DataPoint[int64]{Value: snap.requests}),
+		sum("stinger.responses", "{response}", "total responses number", responses...),
+		sum("stinger.sent_bytes", "By", "sent bytes from client to service",
+			metricdata.DataPoint[int64]{Value: int64(m.SentBytes())}),
+		sum("stinger.received_bytes", "By", "received bytes from service to client",
+			metricdata.DataPoint[int64]{Value: int64(m.ReceivedBytes())}),
+		{
+			Name:        "stinger.inflight",
+			Description: "number of outstanding requests",
+			Unit:        "{request}",
+			Data: metricdata.Gauge[int64]{
+				DataPoints: []metricdata.DataPoint[int64]{{StartTime: start, Time: now, Value: m.Inflight()}},
+			},
+		},
+		{
+			Name:        "stinger.latency",
+			Description: "request latency",
+			Unit:        "s",
+			Data: metricdata.Histogram[float64]{
+				DataPoints: []metricdata.HistogramDataPoint[float64]{
+					otlpHistogram(snap.latencies.all(), e.buckets, start, now),
+				},
+				Temporality: metricdata.CumulativeTemporality,
+			},
+		},
+	}
+	metrics = append(metrics, otlpQuality(qualityScores(snap.latencies, m.quality), start, now)...)
+	metrics = append(metrics, otlpCustom(snap.custom, start, now)...)
+
+	// NOTE: the run metadata goes first, so user attributes override it
+	res, err := resource.Merge(resource.NewSchemaless(otlpRunInfo(m.RunInfo())...), e.resource)
+	if err != nil {
+		res = e.resource
+	}
+
+	return &metricdata.ResourceMetrics{
+		Resource: res,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope:   instrumentation.Scope{Name: "github.com/palage4a/stinger"},
+			Metrics: metrics,
+		}},
+	}
+}
+
+func otlpHistogram(h *Histogram, bounds []time.Duration, start, now time.Time) metricdata.HistogramDataPoint[float64] {
+	p := metricdata.HistogramDataPoint[float64]{
+		StartTime:    start,
+		Time:         now,
+		Count:        uint64(h.Count()), //nolint:gosec
+		Bounds:       make([]float64, len(bounds)),
+		BucketCounts: make([]uint64, len(bounds)+1),
+		Sum:          h.Sum().Seconds(),
+	}
+
+	for i, b := range bounds {
+		p.Bounds[i] = b.Seconds()
+	}
+
+	for _, b := range h.Buckets() {
+		mid := b.Low + (b.High-b.Low)/2
+		i := 0
+		for i < len(bounds) && mid > bounds[i] {
+			i++
+		}
+		p.BucketCounts[i] += uint64(b.Count) //nolint:gosec
+	}
+
+	if h.Count() > 0 {
+		p.Min = metricdata.NewExtrema(h.Min().Seconds())
+		p.Max = metricdata.NewExtrema(h.Max().Seconds())
+	}
+
+	return p
+}
+
+// otlpRunInfo converts the run metadata into resource attributes.
+func otlpRunInfo(info RunInfo) []attribute.KeyValue {
+	res := []attribute.KeyValue{
+		attribute.String("host.name", info.Hostname),
+		attribute.String("process.runtime.version", info.GoVersion),
+		attribute.Int("stinger.gomaxprocs", info.GOMAXPROCS),
+		attribute.String("stinger.version", info.Version),
+		attribute.StringSlice("stinger.targets", info.Targets),
+	}
+	if !info.Start.IsZero() {
+		res = append(res, attribute.String("stinger.start", info.Start.Format(time.RFC3339Nano)))
+	}
+	if info.Revision != "" {
+		res = append(res, attribute.String("stinger.revision", info.Revision))
+	}
+	for k, v := range info.Labels {
+		res = append(res, attribute.String("stinger.label."+k, v))
+	}
+	for k, v := range info.Config {
+		res = append(res, attribute.String("stinger.config."+k, v))
+	}
+
+	return res
+}
+
+// otlpQuality converts scores into gauges, operations are attributes.
+func otlpQuality(scores []Score, start, now time.Time) []metricdata.Metrics {
+	apdex := make([]metricdata.DataPoint[float64], 0, len(scores))
+	slo := make([]metricdata.DataPoint[float64], 0, len(scores))
+	for _, s := range scores {
+		if s.Total == 0 {
+			continue
+		}
+
+		attrs := attribute.NewSet()
+		if s.Name != "" {
+			attrs = attribute.NewSet(attribute.String("operation", s.Name))
+		}
+
+		apdex = append(apdex, metricdata.DataPoint[float64]{Attributes: attrs, StartTime: start, Time: now, Value: s.Apdex()})
+		slo = append(slo, metricdata.DataPoint[float64]{Attributes: attrs, StartTime: start, Time: now, Value: s.Compliance()})
+	}
+
+	if len(apdex) == 0 {
+		return nil
+	}
+
+	return []metricdata.Metrics{
+		{
+			Name:        "stinger.apdex",
+			Description: "apdex score",
+			Unit:        "1",
+			Data:        metricdata.Gauge[float64]{DataPoints: apdex},
+		},
+		{
+			Name:        "stinger.slo_compliance",
+			Description: "share of successful responses within the latency target",
+			Unit:        "1",
+			Data:        metricdata.Gauge[float64]{DataPoints: slo},
+		},
+	}
+}
+
+// otlpCustom converts user-defined metrics, trends become summaries. Metrics
+// of the same name are points of a single metric.
+func otlpCustom(custom []CustomMetric, start, now time.Time) []metricdata.Metrics {
+	res := make([]metricdata.Metrics, 0, len(custom))
+	for first := 0; first < len(custom); {
+		last := first + 1
+		for last < len(custom) && custom[last].Name == custom[first].Name && custom[last].Kind == custom[first].Kind {
+			last++
+		}
+
+		res = append(res, otlpCustomMetric(custom[first:last], start, now))
+		first = last
+	}
+
+	return res
+}
+
+// otlpCustomMetric converts metrics of the same name and kind.
+func otlpCustomMetric(family []CustomMetric, start, now time.Time) metricdata.Metrics {
+	points := make([]metricdata.DataPoint[float64], 0, len(family))
+	summaries := make([]metricdata.SummaryDataPoint, 0)
+	for _, c := range family {
+		attrs := make([]attribute.KeyValue, len(c.Tags))
+		for i, t := range c.Tags {
+			attrs[i] = attribute.String(t.Key, t.Value)
+		}
+		set := attribute.NewSet(attrs...)
+
+		if c.Kind != TrendMetric {
+			points = append(points, metricdata.DataPoint[float64]{Attributes: set, StartTime: start, Time: now, Value: c.Value})
+
+			continue
+		}
+
+		p := metricdata.SummaryDataPoint{
+			Attributes: set,
+			StartTime:  start,
+			Time:       now,
+			Count:      uint64(c.Trend.Count()), //nolint:gosec
+			Sum:        c.Trend.Sum(),
+		}
+		for _, q := range []float64{0, 0.5, 0.9, 0.95, 0.99, 1} {
+			p.QuantileValues = append(p.QuantileValues, metricdata.QuantileValue{Quantile: q, Value: c.Trend.Quantile(q)})
+		}
+		summaries = append(summaries, p)
+	}
+
+	m := metricdata.Metrics{Name: family[0].Name, Description: fmt.Sprintf("user-defined %s", family[0].Kind)}
+	switch family[0].Kind {
+	case CounterMetric:
+		m.Data = metricdata.Sum[float64]{
+			DataPoints:  points,
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+		}
+	case GaugeMetric, RateMetric:
+		m.Data = metricdata.Gauge[float64]{DataPoints: points}
+	case TrendMetric:
+		m.Data = metricdata.Summary{DataPoints: summaries}
+	}
+
+	return m
+}
@@ -0,0 +1,95 @@
+package stinger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExporterHTTP(t *testing.T) {
+	req := &colmetricpb.ExportMetricsServiceRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		assert.NoError(t, proto.Unmarshal(b, req))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	e, err := NewOTLPExporter(context.Background(), OTLPConfig{
+		Endpoint:   srv.URL + "/v1/metrics",
+		Insecure:   true,
+		Attributes: map[string]string{"run": "42"},
+	})
+	assert.NoError(t, err)
+	defer e.Shutdown(context.Background())
+
+	assert.NoError(t, e.Export(context.Background(), newObservedMetrics()))
+
+	assert.Len(t, req.GetResourceMetrics(), 1)
+	rm := req.GetResourceMetrics()[0]
+
+	attrs := make(map[string]string)
+	for _, a := range rm.GetResource().GetAttributes() {
+		attrs[a.GetKey()] = a.GetValue().GetStringValue()
+	}
+	assert.Equal(t, "stinger", attrs["service.name"])
+	assert.Equal(t, "42", attrs["run"])
+	assert.Equal(t, runtime.Version(), attrs["process.runtime.version"])
+	assert.Contains(t, attrs, "stinger.version")
+	assert.Contains(t, attrs, "stinger.start")
+
+	metrics := make(map[string]bool)
+	for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+		metrics[m.GetName()] = true
+
+		switch m.GetName() {
+		case "stinger.requests":
+			assert.Equal(t, int64(1), m.GetSum().GetDataPoints()[0].GetAsInt())
+		case "stinger.inflight":
+			assert.Equal(t, int64(0), m.GetGauge().GetDataPoints()[0].GetAsInt())
+		case "stinger.latency":
+			p := m.GetHistogram().GetDataPoints()[0]
+			assert.Equal(t, uint64(1), p.GetCount())
+			assert.Len(t, p.GetBucketCounts(), len(DefaultLatencyBuckets)+1)
+		case "stinger.apdex", "stinger.slo_compliance":
+			assert.InDelta(t, 1.0, m.GetGauge().GetDataPoints()[0].GetAsDouble(), 0.001)
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"stinger.requests":       true,
+		"stinger.responses":      true,
+		"stinger.inflight":       true,
+		"stinger.sent_bytes":     true,
+		"stinger.received_bytes": true,
+		"stinger.latency":        true,
+		"stinger.apdex":          true,
+		"stinger.slo_compliance": true,
+	}, metrics)
+}
+
+func TestOTLPCustom(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+	m.AddCounter("items", 1, Tag{"region", "eu"})
+	m.AddCounter("items", 2, Tag{"region", "us"})
+	m.AddTrend("size", 1, Tag{"region", "eu"})
+	m.AddTrend("size", 2, Tag{"region", "us"})
+	m.StopTimer()
+
+	metrics := otlpCustom(m.recorder.snapshot().custom, m.start, time.Now())
+	assert.Len(t, metrics, 2, "points of a name must be grouped")
+	assert.Equal(t, "items", metrics[0].Name)
+	assert.Len(t, metrics[0].Data.(metricdata.Sum[float64]).DataPoints, 2)
+	assert.Equal(t, "size", metrics[1].Name)
+	assert.Len(t, metrics[1].Data.(metricdata.Summary).DataPoints, 2)
+}
@@ -0,0 +1,132 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Output receives every timeline interval once it is complete.
+type Output interface {
+	WriteInterval(context.Context, Interval) error
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// mergeLabels returns base labels followed by override, labels of override
+// win those of base with the same name.
+func mergeLabels(base, override []label) []label {
+	res := make([]label, 0, len(base)+len(override))
+	for _, l := range base {
+		if !slices.ContainsFunc(override, func(o label) bool { return o.name == l.name }) {
+			res = append(res, l)
+		}
+	}
+
+	return append(res, override...)
+}
+
+// point is a single value of an interval snapshot.
+type point struct {
+	name   string
+	labels []label
+	value  float64
+	// counter marks values counted within the interval, as opposed to gauges.
+	counter bool
+}
+
+// intervalPoints flattens an interval into points. Latency is in nanoseconds,
+// like the latency summary.
+func intervalPoints(i Interval) []point {
+	var responses, errors int64
+	res := make([]point, 0, len(i.Responses)+16)
+
+	for _, r := range i.Responses {
+		responses += r.Count
+		if !r.Success {
+			errors += r.Count
+		}
+
+		res = append(res, point{"responses", []label{
+			{"code", r.Code},
+			{"success", strconv.FormatBool(r.Success)},
+		}, float64(r.Count), true})
+	}
+
+	res = append(res,
+		point{"requests", nil, float64(i.Requests), true},
+		point{"responses_total", nil, float64(responses), true},
+		point{"errors", nil, float64(errors), true},
+		point{"throughput", nil, i.Throughput(), false},
+		point{"sent_bytes", nil, float64(i.SentBytes), true},
+		point{"received_bytes", nil, float64(i.ReceivedBytes), true},
+		point{"inflight_min", nil, float64(i.InflightMin), false},
+		point{"inflight_avg", nil, i.InflightAvg, false},
+		point{"inflight_max", nil, float64(i.InflightMax), false},
+	)
+
+	if i.Latency != nil && i.Latency.Count() > 0 {
+		res = append(res,
+			point{"latency_min", nil, float64(i.Latency.Min()), false},
+			point{"latency_mean", nil, float64(i.Latency.Mean()), false},
+			point{"latency_p50", nil, float64(i.Latency.Quantile(0.5)), false},
+			point{"latency_p90", nil, float64(i.Latency.Quantile(0.9)), false},
+			point{"latency_p95", nil, float64(i.Latency.Quantile(0.95)), false},
+			point{"latency_p99", nil, float64(i.Latency.Quantile(0.99)), false},
+			point{"latency_max", nil, float64(i.Latency.Max()), false},
+		)
+	}
+
+	if i.Quality.Total > 0 {
+		res = append(res,
+			point{"apdex", nil, i.Quality.Apdex(), false},
+			point{"slo_compliance", nil, i.Quality.Compliance(), false},
+		)
+	}
+
+	return append(res, customPoints(i.Custom)...)
+}
+
+// runLabeled is implemented by outputs tagging their lines with run labels.
+type runLabeled interface {
+	// setRunLabels is called before any interval is written.
+	setRunLabels([]label)
+}
+
+// outputObserver writes complete intervals to outputs.
+type outputObserver struct {
+	NopObserver
+
+	outputs []Output
+}
+
+func (o outputObserver) OnStart(_ context.Context, m *Metrics) {
+	labels := outputRunLabels(m.RunInfo())
+	for _, out := range o.outputs {
+		if l, ok := out.(runLabeled); ok {
+			l.setRunLabels(labels)
+		}
+	}
+}
+
+func (o outputObserver) OnIntervalSnapshot(ctx context.Context, intervals []Interval) {
+	writeIntervals(ctx, intervals, o.outputs)
+}
+
+func writeIntervals(ctx context.Context, intervals []Interval, outputs []Output) {
+	for _, i := range intervals {
+		for _, o := range outputs {
+			ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+			err := o.WriteInterval(ctx, i)
+			cancel()
+
+			if err != nil {
+				fmt.Printf("output err: %s\n", err)
+			}
+		}
+	}
+}
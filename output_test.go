@@ -0,0 +1,175 @@
+package stinger
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testInterval() Interval {
+	latency := NewHistogram()
+	latency.Record(time.Millisecond)
+
+	return Interval{
+		Start:    time.Unix(1700000000, 0),
+		Duration: time.Second,
+		Requests: 2,
+		Responses: []Response{
+			{Code: "OK", Success: true, Count: 1},
+			{Code: "Deadline Exceeded", Success: false, Count: 1},
+		},
+		SentBytes: 10,
+		Latency:   latency,
+	}
+}
+
+func TestInfluxOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.lp")
+	o, err := NewInfluxOutput(InfluxConfig{Path: path, Tags: map[string]string{"run": "a b"}})
+	assert.NoError(t, err)
+
+	i := testInterval()
+	i.Custom = []CustomMetric{{Name: "items", Kind: CounterMetric, Value: 1.5}}
+	assert.NoError(t, o.WriteInterval(context.Background(), i))
+	assert.NoError(t, o.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], `stinger,run=a\ b requests=2,responses_total=2,errors=1,throughput=2,`))
+	assert.Contains(t, lines[0], "latency_p99=1")
+	assert.Contains(t, lines[0], ",items=1.5 ", "fractional counters must be kept")
+	assert.True(t, strings.HasSuffix(lines[0], " 1700000000000000000"))
+	assert.Equal(t, `stinger_responses,run=a\ b,code=OK,success=true value=1 1700000000000000000`, lines[1])
+	assert.Equal(t, `stinger_responses,run=a\ b,code=Deadline\ Exceeded,success=false value=1 1700000000000000000`, lines[2])
+}
+
+func TestInfluxOutputHTTP(t *testing.T) {
+	var auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	o, err := NewInfluxOutput(InfluxConfig{URL: srv.URL, Token: "secret", Measurement: "bench"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, o.WriteInterval(context.Background(), testInterval()))
+	assert.Equal(t, "Token secret", auth)
+	assert.True(t, strings.HasPrefix(string(body), "bench requests=2"))
+}
+
+func TestGraphiteOutput(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	lines := make(chan string, 64)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}()
+
+	o := NewGraphiteOutput(GraphiteConfig{Addr: l.Addr().String()})
+	assert.NoError(t, o.WriteInterval(context.Background(), testInterval()))
+	assert.NoError(t, o.Close())
+
+	assert.Equal(t, "stinger.responses.OK.true 1 1700000000", <-lines)
+	assert.Equal(t, "stinger.responses.Deadline_Exceeded.false 1 1700000000", <-lines)
+	assert.Equal(t, "stinger.requests 2 1700000000", <-lines)
+}
+
+func TestStatsDOutput(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+
+	o, err := NewStatsDOutput(StatsDConfig{Addr: pc.LocalAddr().String(), Tags: map[string]string{"env": "ci"}})
+	assert.NoError(t, err)
+	defer o.Close()
+
+	assert.NoError(t, o.WriteInterval(context.Background(), testInterval()))
+
+	b := make([]byte, statsdPacketSize)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(b)
+	assert.NoError(t, err)
+
+	lines := strings.Split(string(b[:n]), "\n")
+	assert.Equal(t, "stinger.responses:1|c|#env:ci,code:OK,success:true", lines[0])
+	assert.Contains(t, lines, "stinger.requests:2|c|#env:ci")
+	assert.Contains(t, lines, "stinger.throughput:2|g|#env:ci")
+}
+
+func TestStatsDOutputValues(t *testing.T) {
+	o := &StatsDOutput{cfg: StatsDConfig{Prefix: "stinger", DogStatsD: true}}
+
+	assert.Equal(t, "stinger.items:0.5|c", string(o.line(point{name: "items", value: 0.5, counter: true})))
+	assert.Equal(t, "stinger.depth:0|g|#k:v\nstinger.depth:-3|g|#k:v",
+		string(o.line(point{name: "depth", labels: []label{{"k", "v"}}, value: -3})), "negative gauges must be reset first")
+}
+
+func TestStatsDOutputPlain(t *testing.T) {
+	o := &StatsDOutput{cfg: StatsDConfig{Prefix: "stinger"}, mu: &sync.Mutex{}}
+	o.setRunLabels([]label{{"revision", "abc"}})
+
+	assert.Equal(t, "stinger.requests:2|c", string(o.line(point{name: "requests", value: 2, counter: true})),
+		"plain StatsD has no tags")
+}
+
+type memoryOutput struct {
+	intervals []Interval
+}
+
+func (o *memoryOutput) WriteInterval(_ context.Context, i Interval) error {
+	o.intervals = append(o.intervals, i)
+
+	return nil
+}
+
+func TestOutputObserver(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.SetInterval(10 * time.Millisecond)
+	m.StartTimer()
+
+	o := &memoryOutput{}
+	obs := &observerSet{list: []Observer{outputObserver{outputs: []Output{o}}}}
+	stop := startSnapshots(context.Background(), context.Background(), m, obs)
+	for range 5 {
+		m.IncReq(1)
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.StopTimer()
+	stop()
+
+	var requests int64
+	for j, i := range o.intervals {
+		assert.Equal(t, m.start.Add(time.Duration(j)*10*time.Millisecond), i.Start, "every interval once, in order")
+		requests += i.Requests
+	}
+	assert.Equal(t, int64(5), requests)
+}
@@ -0,0 +1,107 @@
+package stinger
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/stats"
+)
+
+// Phase is a part of a request or connection lifetime.
+type Phase string
+
+const (
+	PhaseDNS     Phase = "dns"
+	PhaseConnect Phase = "connect"
+	PhaseTLS     Phase = "tls"
+	// PhaseWrite lasts from the request start till the request is sent.
+	PhaseWrite Phase = "write"
+	// PhaseFirstByte lasts from the request is sent till the first response byte.
+	PhaseFirstByte Phase = "first_byte"
+	// PhaseTransfer lasts from the first response byte till the response is read.
+	PhaseTransfer Phase = "transfer"
+)
+
+// Phases lists the phases in the order they happen.
+var Phases = []Phase{PhaseDNS, PhaseConnect, PhaseTLS, PhaseWrite, PhaseFirstByte, PhaseTransfer}
+
+// ObservePhase records the duration of a request or connection phase.
+func (m *Metrics) ObservePhase(p Phase, d time.Duration) {
+	m.shard.observePhase(p, d)
+}
+
+// phaseCredentials measures the handshake of the wrapped credentials.
+type phaseCredentials struct {
+	credentials.TransportCredentials
+	m *Metrics
+}
+
+func (c *phaseCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	s := time.Now()
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	if err == nil && c.Info().SecurityProtocol != "insecure" {
+		c.m.ObservePhase(PhaseTLS, time.Since(s))
+	}
+
+	return conn, info, err
+}
+
+func (c *phaseCredentials) Clone() credentials.TransportCredentials {
+	return &phaseCredentials{c.TransportCredentials.Clone(), c.m}
+}
+
+type rpcPhasesKey struct{}
+
+type rpcPhases struct {
+	mu        sync.Mutex
+	begin     time.Time
+	sent      time.Time
+	firstByte time.Time
+}
+
+// phaseStats is a grpc stats handler measuring phases of every RPC.
+type phaseStats struct {
+	m *Metrics
+}
+
+func (h *phaseStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcPhasesKey{}, &rpcPhases{})
+}
+
+func (h *phaseStats) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	p, ok := ctx.Value(rpcPhasesKey{}).(*rpcPhases)
+	if !ok || !s.IsClient() {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch s := s.(type) {
+	case *stats.Begin:
+		p.begin = s.BeginTime
+	case *stats.OutPayload:
+		if p.sent.IsZero() {
+			p.sent = s.SentTime
+			h.m.ObservePhase(PhaseWrite, p.sent.Sub(p.begin))
+		}
+	case *stats.InHeader, *stats.InPayload:
+		if p.firstByte.IsZero() && !p.sent.IsZero() {
+			p.firstByte = time.Now()
+			h.m.ObservePhase(PhaseFirstByte, p.firstByte.Sub(p.sent))
+		}
+	case *stats.End:
+		if !p.firstByte.IsZero() {
+			h.m.ObservePhase(PhaseTransfer, s.EndTime.Sub(p.firstByte))
+		}
+	}
+}
+
+func (h *phaseStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *phaseStats) HandleConn(context.Context, stats.ConnStats) {}
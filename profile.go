@@ -0,0 +1,269 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// ProfileKind is a profile of the load generator.
+type ProfileKind string
+
+const (
+	ProfileCPU   ProfileKind = "cpu"
+	ProfileHeap  ProfileKind = "heap"
+	ProfileMutex ProfileKind = "mutex"
+	ProfileBlock ProfileKind = "block"
+	// ProfileTrace is the execution trace, see go tool trace.
+	ProfileTrace ProfileKind = "trace"
+)
+
+// ProfileConfig captures profiles of the load generator for a window of the
+// run, to tell client overhead from the service latency.
+type ProfileConfig struct {
+	// Kinds are captured profiles, none if empty.
+	Kinds []ProfileKind
+	// Dir is where profiles are written, the working directory if empty.
+	Dir string
+	// Delay is the time from the run start to the capture start.
+	Delay time.Duration
+	// Window is the capture length, until the run ends if zero.
+	Window time.Duration
+	// BlockRate is the block profile rate set back after the capture, as the
+	// runtime cannot report the current one. Zero turns block profiling off.
+	BlockRate int
+}
+
+// ProfileFile is a captured profile.
+type ProfileFile struct {
+	Kind ProfileKind
+	Path string
+}
+
+type profiler struct {
+	mu    sync.Mutex
+	files []ProfileFile
+}
+
+func (p *profiler) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.files = nil
+}
+
+func (p *profiler) add(f ProfileFile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.files = append(p.files, f)
+}
+
+func (p *profiler) snapshot() []ProfileFile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res := make([]ProfileFile, len(p.files))
+	copy(res, p.files)
+
+	return res
+}
+
+// capture is a window of profiling. CPU profile and trace are recorded
+// during the window, mutex and block events are sampled only within it and
+// heap is written at its end.
+type capture struct {
+	p      *profiler
+	kinds  []ProfileKind
+	prefix string
+
+	cpu   *os.File
+	trace *os.File
+	// mutexFraction is the previous rate to restore.
+	mutexFraction int
+	// blockRate is the rate to restore.
+	blockRate int
+}
+
+func (c *capture) path(k ProfileKind) string {
+	if k == ProfileTrace {
+		return c.prefix + "trace.out"
+	}
+
+	return c.prefix + string(k) + ".pprof"
+}
+
+func (c *capture) begin() {
+	for _, k := range c.kinds {
+		var err error
+		switch k {
+		case ProfileCPU:
+			c.cpu, err = c.start(k, pprof.StartCPUProfile)
+		case ProfileTrace:
+			c.trace, err = c.start(k, trace.Start)
+		case ProfileMutex:
+			c.mutexFraction = runtime.SetMutexProfileFraction(1)
+		case ProfileBlock:
+			runtime.SetBlockProfileRate(1)
+		}
+
+		if err != nil {
+			fmt.Printf("profile err: %s\n", err)
+		}
+	}
+}
+
+// create creates the file of the profile k, existing files are not overwritten.
+func (c *capture) create(k ProfileKind) (*os.File, error) {
+	return os.OpenFile(c.path(k), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+}
+
+func (c *capture) start(k ProfileKind, start func(w io.Writer) error) (*os.File, error) {
+	f, err := c.create(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := start(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return nil, fmt.Errorf("%s profile: %w", k, err)
+	}
+
+	return f, nil
+}
+
+func (c *capture) end() {
+	for _, k := range c.kinds {
+		var err error
+		switch k {
+		case ProfileCPU:
+			if c.cpu == nil {
+				continue
+			}
+			pprof.StopCPUProfile()
+			err = c.close(k, c.cpu)
+		case ProfileTrace:
+			if c.trace == nil {
+				continue
+			}
+			trace.Stop()
+			err = c.close(k, c.trace)
+		case ProfileHeap:
+			// NOTE: the heap profile is as of the last GC
+			runtime.GC()
+			err = c.write(k, "heap")
+		case ProfileMutex:
+			err = c.write(k, "mutex")
+			runtime.SetMutexProfileFraction(c.mutexFraction)
+		case ProfileBlock:
+			err = c.write(k, "block")
+			runtime.SetBlockProfileRate(c.blockRate)
+		default:
+			err = fmt.Errorf("unknown profile %q", k)
+		}
+
+		if err != nil {
+			fmt.Printf("profile err: %s\n", err)
+		}
+	}
+}
+
+func (c *capture) close(k ProfileKind, f *os.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	c.p.add(ProfileFile{k, f.Name()})
+
+	return nil
+}
+
+func (c *capture) write(k ProfileKind, name string) error {
+	f, err := c.create(k)
+	if err != nil {
+		return err
+	}
+
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		f.Close()
+
+		return fmt.Errorf("%s profile: %w", k, err)
+	}
+
+	return c.close(k, f)
+}
+
+// startProfiling captures profiles for the configured window of the run
+// until ctx is done. The returned func stops the capture and writes profiles.
+func startProfiling(ctx context.Context, m *Metrics, cfg ProfileConfig) func() {
+	m.profiler.reset()
+	if len(cfg.Kinds) == 0 {
+		return func() {}
+	}
+
+	// NOTE: runs, e.g. repeated ones, may start within the same second
+	c := &capture{
+		p:         m.profiler,
+		kinds:     cfg.Kinds,
+		prefix:    filepath.Join(cfg.Dir, "stinger-"+m.start.UTC().Format("20060102T150405.000000000")+"-"),
+		blockRate: cfg.BlockRate,
+	}
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		delay := time.NewTimer(cfg.Delay)
+		defer delay.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-delay.C:
+		}
+
+		var window <-chan time.Time
+		if cfg.Window > 0 {
+			t := time.NewTimer(cfg.Window)
+			defer t.Stop()
+			window = t.C
+		}
+
+		c.begin()
+		select {
+		case <-ctx.Done():
+		case <-done:
+		case <-window:
+		}
+		c.end()
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (r *Result) printProfiles() {
+	if len(r.profiles) == 0 {
+		return
+	}
+
+	fmt.Println("\nPROFILES:")
+	for _, p := range r.profiles {
+		fmt.Printf("%s %s %s\n", p.Kind, getSpacer(string(p.Kind), 30), p.Path)
+	}
+}
@@ -0,0 +1,61 @@
+package stinger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiling(t *testing.T) {
+	dir := t.TempDir()
+	fraction := runtime.SetMutexProfileFraction(-1)
+
+	r := Benchmark(context.Background(), newIsolatedMetrics(), BenchmarkConfig{
+		Duration: 50 * time.Millisecond,
+		Profile: ProfileConfig{
+			Kinds:  []ProfileKind{ProfileCPU, ProfileHeap, ProfileMutex, ProfileBlock, ProfileTrace},
+			Dir:    dir,
+			Delay:  10 * time.Millisecond,
+			Window: 20 * time.Millisecond,
+		},
+	}, sleepRunner{})
+
+	prefix := filepath.Join(dir, "stinger-"+r.RunInfo().Start.UTC().Format("20060102T150405.000000000")+"-")
+	assert.Equal(t, []ProfileFile{
+		{ProfileCPU, prefix + "cpu.pprof"},
+		{ProfileHeap, prefix + "heap.pprof"},
+		{ProfileMutex, prefix + "mutex.pprof"},
+		{ProfileBlock, prefix + "block.pprof"},
+		{ProfileTrace, prefix + "trace.out"},
+	}, r.Profiles())
+
+	for _, p := range r.Profiles() {
+		fi, err := os.Stat(p.Path)
+		if assert.NoError(t, err) {
+			assert.Positive(t, fi.Size(), p.Kind)
+		}
+	}
+	assert.Equal(t, fraction, runtime.SetMutexProfileFraction(-1), "the mutex rate is restored")
+}
+
+func TestProfilingDisabled(t *testing.T) {
+	r := Benchmark(context.Background(), newIsolatedMetrics(), BenchmarkConfig{
+		Duration: 10 * time.Millisecond,
+	}, sleepRunner{})
+
+	assert.Empty(t, r.Profiles())
+}
+
+func TestProfilingNoOverwrite(t *testing.T) {
+	p := &profiler{}
+	c := &capture{p: p, prefix: filepath.Join(t.TempDir(), "stinger-")}
+
+	assert.NoError(t, c.write(ProfileHeap, "heap"))
+	assert.ErrorIs(t, c.write(ProfileHeap, "heap"), os.ErrExist)
+	assert.Len(t, p.snapshot(), 1)
+}
@@ -0,0 +1,47 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+type PushgatewayConfig struct {
+	URL      string
+	Job      string
+	Grouping map[string]string
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// PushgatewayExporter replaces the metrics of its group on a Pushgateway on every export.
+type PushgatewayExporter struct {
+	cfg PushgatewayConfig
+}
+
+func NewPushgatewayExporter(cfg PushgatewayConfig) *PushgatewayExporter {
+	if cfg.Job == "" {
+		cfg.Job = "stinger"
+	}
+
+	return &PushgatewayExporter{cfg}
+}
+
+func (e *PushgatewayExporter) Export(ctx context.Context, m *Metrics) error {
+	p := push.New(e.cfg.URL, e.cfg.Job).Gatherer(m.Gatherer())
+	for k, v := range e.cfg.Grouping {
+		p = p.Grouping(k, v)
+	}
+
+	if e.cfg.Client != nil {
+		p = p.Client(e.cfg.Client)
+	}
+
+	if err := p.PushContext(ctx); err != nil {
+		return fmt.Errorf("pushgateway: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package stinger
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultSatisfied is the Apdex target latency used if none is set.
+const DefaultSatisfied = 500 * time.Millisecond
+
+// Quality sets latency thresholds of Apdex and SLO compliance scores. Scores
+// are reported only, they never fail the run.
+type Quality struct {
+	// Satisfied is the Apdex target latency, DefaultSatisfied if zero.
+	Satisfied time.Duration
+	// Tolerated is the latency till which responses are tolerated, 4x Satisfied if zero.
+	Tolerated time.Duration
+	// Target is the SLO latency target, Satisfied if zero.
+	Target time.Duration
+}
+
+func (q Quality) satisfied() time.Duration {
+	if q.Satisfied > 0 {
+		return q.Satisfied
+	}
+
+	return DefaultSatisfied
+}
+
+func (q Quality) tolerated() time.Duration {
+	if q.Tolerated > 0 {
+		return q.Tolerated
+	}
+
+	return 4 * q.satisfied()
+}
+
+func (q Quality) target() time.Duration {
+	if q.Target > 0 {
+		return q.Target
+	}
+
+	return q.satisfied()
+}
+
+// Score rates responses against Quality thresholds. Failed responses are
+// never satisfied, tolerated or compliant.
+type Score struct {
+	// Name is the operation tag as key=value, empty for the whole run.
+	Name  string
+	Total int64
+	// Satisfied are successful responses within the Satisfied latency.
+	Satisfied int64
+	// Tolerated are successful responses within the Tolerated latency but
+	// over the Satisfied one.
+	Tolerated int64
+	// Compliant are successful responses within the SLO target.
+	Compliant int64
+}
+
+// Apdex returns (satisfied + tolerated/2) / total, zero if there are no responses.
+func (s Score) Apdex() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+
+	return (float64(s.Satisfied) + float64(s.Tolerated)/2) / float64(s.Total)
+}
+
+// Compliance returns the share (0..1) of successful responses within the SLO target.
+func (s Score) Compliance() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+
+	return float64(s.Compliant) / float64(s.Total)
+}
+
+// newScore rates responses of the all distribution, failed is its part of
+// unsuccessful ones.
+func newScore(name string, all, failed *Histogram, q Quality) Score {
+	within := func(d time.Duration) int64 {
+		n := all.CountBelow(d)
+		if failed != nil {
+			n -= failed.CountBelow(d)
+		}
+
+		return n
+	}
+
+	satisfied := within(q.satisfied())
+
+	return Score{
+		Name:      name,
+		Total:     all.Count(),
+		Satisfied: satisfied,
+		Tolerated: within(q.tolerated()) - satisfied,
+		Compliant: within(q.target()),
+	}
+}
+
+// qualityScores returns the score of the whole run followed by scores of
+// operations, which are request tags, sorted by name.
+func qualityScores(l *latencies, q Quality) []Score {
+	all := NewHistogram()
+	all.Merge(l.success)
+	all.Merge(l.failure)
+
+	res := []Score{newScore("", all, l.failure, q)}
+	for t, h := range l.tags {
+		res = append(res, newScore(t.Key+"="+t.Value, h, l.failedTags[t], q))
+	}
+
+	sort.Slice(res[1:], func(i, j int) bool {
+		return res[1+i].Name < res[1+j].Name
+	})
+
+	return res
+}
+
+// SetQuality sets thresholds of Apdex and SLO compliance scores. Must be called before StartTimer.
+func (m *Metrics) SetQuality(q Quality) {
+	m.quality = q
+}
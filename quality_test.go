@@ -0,0 +1,43 @@
+package stinger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityScores(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.SetQuality(Quality{Satisfied: 10 * time.Millisecond, Tolerated: 40 * time.Millisecond, Target: 20 * time.Millisecond})
+	m.StartTimer()
+
+	a, b := []Tag{{"method", "A"}}, []Tag{{"method", "B"}}
+	for _, s := range []Sample{
+		{Latency: 5 * time.Millisecond, Success: true, Tags: a},
+		{Latency: 15 * time.Millisecond, Success: true, Tags: a},
+		{Latency: 30 * time.Millisecond, Success: true, Tags: b},
+		{Latency: 50 * time.Millisecond, Success: true, Tags: b},
+		{Latency: 5 * time.Millisecond, Success: false, Tags: b},
+	} {
+		s.Start, s.Code = m.start, "OK"
+		m.replay(s)
+	}
+	m.StopTimer()
+
+	r := m.Result()
+	total := Score{Total: 5, Satisfied: 1, Tolerated: 2, Compliant: 2}
+	assert.Equal(t, total, r.Quality())
+	assert.InDelta(t, 0.4, r.Quality().Apdex(), 0.001)
+	assert.InDelta(t, 0.4, r.Quality().Compliance(), 0.001)
+
+	ops := r.OperationQuality()
+	assert.Equal(t, []Score{
+		{Name: "method=A", Total: 2, Satisfied: 1, Tolerated: 1, Compliant: 2},
+		{Name: "method=B", Total: 3, Tolerated: 1},
+	}, ops)
+	assert.InDelta(t, 0.75, ops[0].Apdex(), 0.001)
+	assert.InDelta(t, 1.0/6, ops[1].Apdex(), 0.001)
+
+	assert.Equal(t, total, r.Timeline()[0].Quality)
+}
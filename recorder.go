@@ -0,0 +1,369 @@
+package stinger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shard records observations of a single metrics handle. Every actor has its
+// own, so the lock is contended only by snapshots and recording stays cheap
+// at any concurrency.
+type shard struct {
+	mu sync.Mutex
+	// begun counts requests begun, it is updated without mu. Requests are
+	// outstanding until counted by ended.
+	begun     atomic.Int64
+	ended     int64
+	requests  int64
+	responses *responseCounts
+	latencies *latencies
+	custom    customSet
+	checks    checkSet
+	timeline  *timeline
+}
+
+func newShard() *shard {
+	return &shard{
+		responses: newResponseCounts(),
+		latencies: newLatencies(),
+		custom:    make(customSet),
+		checks:    make(checkSet),
+		timeline:  newTimeline(DefaultInterval),
+	}
+}
+
+func (s *shard) addRequests(ts time.Time, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests += n
+	s.timeline.addRequests(ts, n)
+}
+
+// stale reports whether the shard keeps complete buckets, which hold every
+// in-flight change, as no snapshot flushed them. Must be called with mu held.
+func (s *shard) stale() bool {
+	return len(s.timeline.buckets) > 2
+}
+
+// startRequest records a request started at ts and reports whether the shard is stale.
+func (s *shard) startRequest(ts time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	s.timeline.addRequests(ts, 1)
+	s.timeline.addInflight(ts, 1)
+
+	return s.stale()
+}
+
+// endRequest records a response received at ts of a request recorded by
+// startRequest and reports whether the shard is stale.
+func (s *shard) endRequest(ts time.Time, latency time.Duration, code string, success bool, scenario string, tags []Tag) bool {
+	key := responseKey{code, success}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ended++
+	s.responses.add(key, 1)
+	s.timeline.addInflight(ts, -1)
+	s.timeline.addResponse(ts, key, 1, latency)
+	s.latencies.record(latency, code, success, scenario, tags)
+
+	return s.stale()
+}
+
+// completeRequest records a request answered at ts after latency at once,
+// which takes the lock once instead of twice. It reports whether the shard
+// is stale.
+func (s *shard) completeRequest(ts time.Time, latency time.Duration, code string, success bool, scenario string, tags []Tag) bool {
+	key := responseKey{code, success}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	s.ended++
+	s.responses.add(key, 1)
+	s.timeline.addRequest(ts, key, latency)
+	s.latencies.record(latency, code, success, scenario, tags)
+
+	return s.stale()
+}
+
+func (s *shard) addResponses(ts time.Time, code string, success bool, n int64) {
+	key := responseKey{code, success}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses.add(key, n)
+	s.timeline.addResponse(ts, key, n, -1)
+}
+
+// replay records a previously logged sample.
+func (s *shard) replay(sample Sample) {
+	key := responseKey{sample.Code, sample.Success}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	s.responses.add(key, 1)
+	s.timeline.addRequests(sample.Start, 1)
+	s.timeline.addResponse(sample.End(), key, 1, sample.Latency)
+	s.timeline.addBytes(sample.End(), sample.SentBytes, sample.ReceivedBytes)
+	s.latencies.record(sample.Latency, sample.Code, sample.Success, sample.Scenario, sample.Tags)
+}
+
+func (s *shard) addBytes(ts time.Time, sent, received uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timeline.addBytes(ts, sent, received)
+}
+
+func (s *shard) observeCustom(ts time.Time, kind MetricKind, name string, tags []Tag, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.custom.observe(ts, kind, name, tags, v)
+	s.timeline.addCustom(ts, kind, name, tags, v)
+}
+
+// check records the outcome of a check, order returns its run-wide order.
+func (s *shard) check(name string, ok bool, order func(string) int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checks.record(name, ok, order)
+}
+
+func (s *shard) observePhase(p Phase, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latencies.phase(p).Record(d)
+}
+
+// recorderSnapshot is the run-wide part of all shards merged.
+type recorderSnapshot struct {
+	requests  int64
+	responses map[responseKey]int64
+	latencies *latencies
+	custom    []CustomMetric
+	checks    []CheckResult
+}
+
+// recorder owns the shards of all metrics handles and merges them on demand.
+// Complete timeline buckets are flushed from shards into the recorder, so
+// shards keep only the current ones. Locks are taken recorder first.
+type recorder struct {
+	mu     sync.Mutex
+	shards []*shard
+	start  time.Time
+	end    time.Time
+	width  time.Duration
+	// buckets are merged buckets flushed from shards.
+	buckets []*bucket
+}
+
+func newRecorder(width time.Duration) *recorder {
+	return &recorder{width: width}
+}
+
+func (r *recorder) newShard() *shard {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := newShard()
+	s.timeline.reset(r.start, r.width)
+	s.timeline.base = len(r.buckets)
+	r.shards = append(r.shards, s)
+
+	return s
+}
+
+// inflight returns the number of outstanding requests of all shards.
+func (r *recorder) inflight() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for _, s := range r.shards {
+		s.mu.Lock()
+		n += s.begun.Load() - s.ended
+		s.mu.Unlock()
+	}
+
+	return n
+}
+
+func (r *recorder) interval() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.width
+}
+
+func (r *recorder) setInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.width = d
+}
+
+func (r *recorder) reset(start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.start = start
+	r.end = time.Time{}
+	r.buckets = nil
+	for _, s := range r.shards {
+		s.mu.Lock()
+		s.timeline.reset(start, r.width)
+		s.mu.Unlock()
+	}
+}
+
+func (r *recorder) stop(end time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.end = end
+}
+
+// flush merges shard buckets before the index upTo. Must be called with mu held.
+func (r *recorder) flush(upTo int) {
+	if upTo <= len(r.buckets) {
+		return
+	}
+
+	from := len(r.buckets)
+	for len(r.buckets) < upTo {
+		r.buckets = append(r.buckets, newBucket())
+	}
+
+	for _, s := range r.shards {
+		s.mu.Lock()
+		flushed := s.timeline.flush(upTo, r.end)
+		s.mu.Unlock()
+
+		base := upTo - len(flushed)
+		for j, b := range flushed {
+			r.buckets[base+j].merge(b)
+		}
+	}
+
+	for i := from; i < upTo; i++ {
+		r.buckets[i].settleInflight(time.Duration(i) * r.width)
+	}
+}
+
+// flushBefore flushes buckets complete at ts of the running timeline, so
+// stale shards do not grow when no one takes snapshots.
+func (r *recorder) flushBefore(ts time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.start.IsZero() || !r.end.IsZero() || ts.Before(r.start) {
+		return
+	}
+
+	r.flush(int(ts.Sub(r.start) / r.width))
+}
+
+// snapshotFrom returns the timeline starting from the interval from. The
+// last interval is cut at the stop time, or at now if the run goes on.
+func (r *recorder) snapshotFrom(from int) []Interval {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.start.IsZero() {
+		return nil
+	}
+
+	end, running := r.end, r.end.IsZero()
+	if running {
+		end = time.Now()
+	}
+
+	var n int
+	if running {
+		n = int(end.Sub(r.start)/r.width) + 1
+		r.flush(n - 1)
+	} else {
+		// NOTE: responses received right at the stop time get an interval too
+		n = int((end.Sub(r.start) + r.width - 1) / r.width)
+		for _, s := range r.shards {
+			s.mu.Lock()
+			n = max(n, s.timeline.size())
+			s.mu.Unlock()
+		}
+		r.flush(n)
+	}
+
+	if from >= n {
+		return nil
+	}
+
+	buckets := r.buckets[:min(n, len(r.buckets))]
+	if running {
+		current := newBucket()
+		for _, s := range r.shards {
+			s.mu.Lock()
+			pending := s.timeline.pending(n-1, end)
+			s.mu.Unlock()
+
+			if len(pending) > 0 {
+				current.merge(pending[len(pending)-1])
+			}
+		}
+		current.settleInflight(time.Duration(n-1) * r.width)
+		buckets = append(buckets[:len(buckets):len(buckets)], current)
+	}
+
+	res := make([]Interval, 0, n-from)
+	for i := from; i < n; i++ {
+		start := r.start.Add(time.Duration(i) * r.width)
+		d := r.width
+		if rest := end.Sub(start); rest > 0 && rest < d {
+			d = rest
+		}
+
+		res = append(res, buckets[i].toInterval(start, d))
+	}
+
+	return res
+}
+
+// snapshot merges the run-wide part of all shards.
+func (r *recorder) snapshot() recorderSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := recorderSnapshot{
+		responses: make(map[responseKey]int64),
+		latencies: newLatencies(),
+	}
+	custom := make(customSet)
+	checks := make(checkSet)
+
+	for _, s := range r.shards {
+		s.mu.Lock()
+		res.requests += s.requests
+		s.responses.addTo(res.responses)
+		res.latencies.merge(s.latencies)
+		custom.merge(s.custom)
+		checks.merge(s.checks)
+		s.mu.Unlock()
+	}
+	res.custom = custom.snapshot()
+	res.checks = checks.snapshot()
+
+	return res
+}
@@ -0,0 +1,168 @@
+package stinger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type RemoteWriteConfig struct {
+	URL string
+	// Labels are attached to every series, e.g. job or instance.
+	Labels  map[string]string
+	Headers map[string]string
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// RemoteWriteExporter sends the current value of every metric to a Prometheus
+// remote-write (v1) endpoint.
+type RemoteWriteExporter struct {
+	cfg RemoteWriteConfig
+}
+
+func NewRemoteWriteExporter(cfg RemoteWriteConfig) *RemoteWriteExporter {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &RemoteWriteExporter{cfg}
+}
+
+type rwSeries struct {
+	labels []label
+	value  float64
+}
+
+func (e *RemoteWriteExporter) Export(ctx context.Context, m *Metrics) error {
+	families, err := m.Gatherer().Gather()
+	if err != nil {
+		return fmt.Errorf("remote write: gather err: %w", err)
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(e.series(families), time.Now().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("remote write: new request err: %w", err)
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote write: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return fmt.Errorf("remote write: unexpected status %d: %s", resp.StatusCode, msg)
+	}
+
+	return nil
+}
+
+func (e *RemoteWriteExporter) series(families []*dto.MetricFamily) []rwSeries {
+	res := make([]rwSeries, 0)
+	base := sortedLabels(e.cfg.Labels)
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, metric := range mf.GetMetric() {
+			add := func(suffix string, v float64, extra ...label) {
+				own := make([]label, 0, len(metric.GetLabel())+len(extra)+1)
+				own = append(own, label{"__name__", name + suffix})
+				for _, l := range metric.GetLabel() {
+					own = append(own, label{l.GetName(), l.GetValue()})
+				}
+				own = append(own, extra...)
+
+				// NOTE: label names must be unique, so metric labels win configured ones
+				labels := mergeLabels(base, own)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				res = append(res, rwSeries{labels, v})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", metric.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", metric.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", metric.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := metric.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), label{"quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := metric.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add("_bucket", float64(b.GetCumulativeCount()), label{"le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)})
+				}
+				add("_bucket", float64(h.GetSampleCount()), label{"le", strconv.FormatFloat(math.Inf(1), 'g', -1, 64)})
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return res
+}
+
+// encodeWriteRequest encodes prometheus.WriteRequest protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label { string name = 1; string value = 2; }
+//	Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []rwSeries, ts int64) []byte {
+	var b []byte
+
+	for _, s := range series {
+		var sb []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+
+			sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+			sb = protowire.AppendBytes(sb, lb)
+		}
+
+		var smp []byte
+		smp = protowire.AppendTag(smp, 1, protowire.Fixed64Type)
+		smp = protowire.AppendFixed64(smp, math.Float64bits(s.value))
+		smp = protowire.AppendTag(smp, 2, protowire.VarintType)
+		smp = protowire.AppendVarint(smp, uint64(ts)) //nolint:gosec
+
+		sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+		sb = protowire.AppendBytes(sb, smp)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+
+	return b
+}
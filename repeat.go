@@ -0,0 +1,283 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultConfidence is the confidence level of repeated run intervals.
+	DefaultConfidence = 0.95
+	// DefaultMaxVariation is the coefficient of variation above which a
+	// statistic of repeated runs is unstable.
+	DefaultMaxVariation = 0.1
+)
+
+// RepeatConfig makes BenchmarkRepeated run the same benchmark several times.
+type RepeatConfig struct {
+	// Runs is the number of runs, statistics need at least two.
+	Runs int
+	// CoolDown is the pause between runs.
+	CoolDown time.Duration
+	// Confidence is the level (0..1) of confidence intervals, DefaultConfidence if zero.
+	Confidence float64
+	// MaxVariation is the stddev to mean ratio above which a statistic is
+	// unstable, DefaultMaxVariation if zero.
+	MaxVariation float64
+	// Metrics returns metrics of every run, isolated ones registered in
+	// their own registry if nil.
+	Metrics func() *Metrics
+}
+
+// Estimate is a statistic over repeated runs.
+type Estimate struct {
+	Values []float64
+	Mean   float64
+	// Stddev is the sample standard deviation.
+	Stddev float64
+	// Low and High bound the confidence interval of the mean.
+	Low  float64
+	High float64
+	// Unstable marks the variation above the limit.
+	Unstable bool
+}
+
+// Variation returns the coefficient of variation, stddev to mean ratio.
+func (e Estimate) Variation() float64 {
+	if e.Mean == 0 {
+		return 0
+	}
+
+	return e.Stddev / math.Abs(e.Mean)
+}
+
+// PercentileEstimate is the estimate of a latency percentile in nanoseconds.
+type PercentileEstimate struct {
+	Percentile float64
+	Estimate
+}
+
+// RepeatedResult holds results of repeated runs and statistics over them.
+type RepeatedResult struct {
+	Runs       []*Result
+	Throughput Estimate
+	// Percentiles are estimates of latency percentiles of all responses.
+	Percentiles []PercentileEstimate
+	Confidence  float64
+}
+
+// Unstable reports whether any statistic varies above the limit.
+func (r *RepeatedResult) Unstable() bool {
+	if r.Throughput.Unstable {
+		return true
+	}
+
+	for _, p := range r.Percentiles {
+		if p.Unstable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BenchmarkRepeated runs Benchmark with the same configuration rc.Runs times
+// on fresh metrics. The run number is added to the run labels as "repeat".
+func BenchmarkRepeated(ctx context.Context, cfg BenchmarkConfig, rc RepeatConfig, runners ...Runnable) *RepeatedResult {
+	if rc.Confidence <= 0 || rc.Confidence >= 1 {
+		rc.Confidence = DefaultConfidence
+	}
+	if rc.MaxVariation <= 0 {
+		rc.MaxVariation = DefaultMaxVariation
+	}
+	if rc.Metrics == nil {
+		rc.Metrics = newIsolatedMetrics
+	}
+
+	results := make([]*Result, 0, rc.Runs)
+	for i := range rc.Runs {
+		if i > 0 && rc.CoolDown > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(rc.CoolDown):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		runCfg := cfg
+		runCfg.Labels = copyLabels(cfg.Labels)
+		if runCfg.Labels == nil {
+			runCfg.Labels = make(map[string]string)
+		}
+		runCfg.Labels["repeat"] = strconv.Itoa(i + 1)
+
+		results = append(results, Benchmark(ctx, rc.Metrics(), runCfg, runners...))
+	}
+
+	return newRepeatedResult(results, rc)
+}
+
+func newRepeatedResult(results []*Result, rc RepeatConfig) *RepeatedResult {
+	throughput := make([]float64, len(results))
+	for i, r := range results {
+		throughput[i] = r.Throughput()
+	}
+
+	res := &RepeatedResult{
+		Runs:       results,
+		Throughput: newEstimate(throughput, rc.Confidence, rc.MaxVariation),
+		Confidence: rc.Confidence,
+	}
+
+	for _, q := range latencyQuantiles {
+		values := make([]float64, len(results))
+		for i, r := range results {
+			values[i] = float64(r.Percentile(q * 100))
+		}
+
+		res.Percentiles = append(res.Percentiles, PercentileEstimate{
+			Percentile: q * 100,
+			Estimate:   newEstimate(values, rc.Confidence, rc.MaxVariation),
+		})
+	}
+
+	return res
+}
+
+// newEstimate computes the mean and its Student's t confidence interval.
+func newEstimate(values []float64, confidence, maxVariation float64) Estimate {
+	e := Estimate{Values: values}
+	n := float64(len(values))
+	if n == 0 {
+		return e
+	}
+
+	for _, v := range values {
+		e.Mean += v
+	}
+	e.Mean /= n
+	e.Low, e.High = e.Mean, e.Mean
+
+	if n < 2 {
+		return e
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - e.Mean) * (v - e.Mean)
+	}
+	e.Stddev = math.Sqrt(squares / (n - 1))
+
+	margin := studentT((1+confidence)/2, n-1) * e.Stddev / math.Sqrt(n)
+	e.Low, e.High = e.Mean-margin, e.Mean+margin
+	e.Unstable = e.Variation() > maxVariation
+
+	return e
+}
+
+// studentT returns the quantile p (0.5..1) of the Student's t-distribution
+// with df degrees of freedom.
+func studentT(p, df float64) float64 {
+	// NOTE: the CDF grows monotonically, so bisection converges
+	lo, hi := 0.0, 1e6
+	for range 200 {
+		mid := (lo + hi) / 2
+		if studentCDF(mid, df) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return (lo + hi) / 2
+}
+
+// studentCDF is the Student's t-distribution CDF for t >= 0.
+func studentCDF(t, df float64) float64 {
+	return 1 - incompleteBeta(df/(df+t*t), df/2, 0.5)/2
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b).
+func incompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+
+	// NOTE: the continued fraction converges fast below the mean only
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(x, a, b) / a
+	}
+
+	return 1 - front*betaFraction(1-x, b, a)/b
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta
+// function by the modified Lentz's method.
+func betaFraction(x, a, b float64) float64 {
+	const eps, tiny = 1e-14, 1e-300
+
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+
+		return v
+	}
+
+	c, d := 1.0, 1/clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		aa := m * (b - m) * x / ((a - 1 + 2*m) * (a + 2*m))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		h *= d * c
+
+		aa = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 1 + 2*m))
+		d = 1 / clamp(1+aa*d)
+		c = clamp(1 + aa/c)
+		step := d * c
+		h *= step
+
+		if math.Abs(step-1) < eps {
+			break
+		}
+	}
+
+	return h
+}
+
+// Print writes statistics of the runs to stdout.
+func (r *RepeatedResult) Print() {
+	fmt.Printf("\nREPEATED RUNS: %d confidence %0.0f%%\n", len(r.Runs), r.Confidence*100)
+
+	mark := func(e Estimate) string {
+		if e.Unstable {
+			return fmt.Sprintf(" UNSTABLE (cv %0.1f%%)", e.Variation()*100)
+		}
+
+		return ""
+	}
+
+	e := r.Throughput
+	fmt.Printf("throughput .................... %0.2f ± %0.2f req/s [%0.2f, %0.2f]%s\n",
+		e.Mean, e.Stddev, e.Low, e.High, mark(e))
+
+	for _, p := range r.Percentiles {
+		name := fmt.Sprintf("latency p(%g)", p.Percentile)
+		fmt.Printf("%s %s %s ± %s [%s, %s]%s\n", name, getSpacer(name, 30),
+			time.Duration(p.Mean), time.Duration(p.Stddev), time.Duration(p.Low), time.Duration(p.High), mark(p.Estimate))
+	}
+}
@@ -0,0 +1,74 @@
+package stinger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStudentT(t *testing.T) {
+	assert.InDelta(t, 12.706, studentT(0.975, 1), 0.001)
+	assert.InDelta(t, 2.228, studentT(0.975, 10), 0.001)
+	assert.InDelta(t, 2.764, studentT(0.99, 10), 0.001)
+	assert.InDelta(t, 1.962, studentT(0.975, 1000), 0.001)
+}
+
+func TestEstimate(t *testing.T) {
+	e := newEstimate([]float64{9, 10, 11}, 0.95, 0.1)
+	assert.InDelta(t, 10, e.Mean, 0.001)
+	assert.InDelta(t, 1, e.Stddev, 0.001)
+	assert.InDelta(t, 10-4.303/1.732, e.Low, 0.001)
+	assert.InDelta(t, 10+4.303/1.732, e.High, 0.001)
+	assert.False(t, e.Unstable)
+
+	e = newEstimate([]float64{5, 10, 15}, 0.95, 0.1)
+	assert.True(t, e.Unstable)
+	assert.InDelta(t, 0.5, e.Variation(), 0.001)
+
+	e = newEstimate([]float64{7}, 0.95, 0.1)
+	assert.Equal(t, Estimate{Values: []float64{7}, Mean: 7, Low: 7, High: 7}, e)
+}
+
+type sleepActor struct{}
+
+func (sleepActor) Run(m *Metrics) error {
+	return m.ObserveRequest(func() (string, bool, error) {
+		time.Sleep(time.Millisecond)
+
+		return "OK", true, nil
+	})
+}
+
+type sleepRunner struct{}
+
+func (sleepRunner) SetUp(context.Context) {}
+func (sleepRunner) Parallelism() int      { return 2 }
+
+func (sleepRunner) ActorSetup(context.Context, int) (Actor, error) {
+	return sleepActor{}, nil
+}
+
+func TestBenchmarkRepeated(t *testing.T) {
+	start := time.Now()
+	res := BenchmarkRepeated(context.Background(), BenchmarkConfig{
+		Duration: 30 * time.Millisecond,
+		Labels:   map[string]string{"env": "test"},
+	}, RepeatConfig{Runs: 3, CoolDown: 10 * time.Millisecond}, sleepRunner{})
+
+	assert.GreaterOrEqual(t, time.Since(start), 110*time.Millisecond)
+	assert.Len(t, res.Runs, 3)
+	for i, r := range res.Runs {
+		assert.Positive(t, r.Requests())
+		assert.Equal(t, map[string]string{"env": "test", "repeat": []string{"1", "2", "3"}[i]}, r.RunInfo().Labels)
+	}
+
+	assert.Len(t, res.Throughput.Values, 3)
+	assert.Positive(t, res.Throughput.Mean)
+	assert.LessOrEqual(t, res.Throughput.Low, res.Throughput.Mean)
+	assert.GreaterOrEqual(t, res.Throughput.High, res.Throughput.Mean)
+	assert.Len(t, res.Percentiles, 4)
+	assert.InDelta(t, 99, res.Percentiles[3].Percentile, 0.001)
+	assert.GreaterOrEqual(t, res.Percentiles[0].Mean, float64(time.Millisecond))
+}
@@ -0,0 +1,138 @@
+package stinger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PendingRequest is an outstanding request started by StartRequest.
+type PendingRequest struct {
+	m *Metrics
+	// info is nil for requests without options.
+	info  *requestInfo
+	start time.Time
+	ended atomic.Bool
+	// started marks requests recorded on start, others are recorded on End.
+	started bool
+
+	sent     uint64
+	received uint64
+}
+
+// StartRequest starts measuring a request completed asynchronously, e.g. a
+// streamed response, by End. The request is in flight until then. Sample
+// bytes are those of the actor between start and end, so they are exact
+// only for one outstanding request per actor.
+func (m *Metrics) StartRequest(opts ...RequestOption) *PendingRequest {
+	r := new(PendingRequest)
+	r.begin(m, opts)
+	r.started = true
+	if m.shard.startRequest(r.start) {
+		m.recorder.flushBefore(r.start)
+	}
+
+	return r
+}
+
+// ObserveRequest measures the request f, which returns the response code and
+// success. The request is recorded once f returns.
+func (m *Metrics) ObserveRequest(f func() (string, bool, error), opts ...RequestOption) error {
+	// NOTE: the request does not escape, so requests without options do not allocate
+	var r PendingRequest
+	r.begin(m, opts)
+	code, success, err := f()
+
+	// NOTE: the request is not shared, so it ends once
+	return r.end(code, success, err)
+}
+
+func (r *PendingRequest) begin(m *Metrics, opts []RequestOption) {
+	r.m = m
+	if len(opts) > 0 {
+		r.info = new(requestInfo)
+		for _, o := range opts {
+			o(r.info)
+		}
+	}
+
+	if m.samples != nil {
+		r.sent, r.received = m.bytes.load()
+	}
+
+	r.start = time.Now()
+	m.shard.begun.Add(1)
+}
+
+// End records the response of the request and returns err. Repeated calls are ignored.
+func (r *PendingRequest) End(code string, success bool, err error) error {
+	if !r.ended.CompareAndSwap(false, true) {
+		return err
+	}
+
+	return r.end(code, success, err)
+}
+
+// noInfo is the info of requests without options.
+var noInfo requestInfo
+
+func (r *PendingRequest) end(code string, success bool, err error) error {
+	m, s := r.m, r.start
+	e := time.Now()
+	latency := e.Sub(s)
+
+	info := r.info
+	if info == nil {
+		info = &noInfo
+	}
+
+	// NOTE: synchronous requests are short, so they are recorded once complete
+	var stale bool
+	if r.started {
+		stale = m.shard.endRequest(e, latency, code, success, m.scenario, info.tags)
+	} else {
+		stale = m.shard.completeRequest(e, latency, code, success, m.scenario, info.tags)
+	}
+	if stale {
+		m.recorder.flushBefore(e)
+	}
+
+	if m.slowest.keeps(latency) {
+		m.slowest.record(SlowRequest{
+			Start:    s,
+			Latency:  latency,
+			Actor:    m.actor,
+			Scenario: m.scenario,
+			Target:   info.target,
+			Code:     code,
+			Success:  success,
+			Payload:  info.payload,
+			Tags:     info.tags,
+		})
+	}
+
+	for _, c := range info.checks {
+		m.Check(c.name, c.validator(code, success, err))
+	}
+
+	if m.samples != nil {
+		sentAfter, receivedAfter := m.bytes.load()
+		m.samples.WriteSample(Sample{
+			Start:         s,
+			Latency:       latency,
+			Code:          code,
+			Success:       success,
+			Actor:         m.actor,
+			Scenario:      m.scenario,
+			SentBytes:     sentAfter - r.sent,
+			ReceivedBytes: receivedAfter - r.received,
+			Tags:          info.tags,
+		})
+	}
+
+	return err
+}
+
+// Inflight returns the number of outstanding requests.
+func (m *Metrics) Inflight() int64 {
+	return m.recorder.inflight()
+}
@@ -0,0 +1,674 @@
+package stinger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result is a snapshot of the benchmark metrics.
+type Result struct {
+	latency       []LatencyPercentile
+	duration      time.Duration
+	requests      int64
+	responses     []Response
+	sentBytes     uint64
+	receivedBytes uint64
+	traffic       TrafficStats
+	targets       []TrafficStats
+	runners       []TrafficStats
+	timeline      []Interval
+	latencies     *latencies
+	runtime       []RuntimeSample
+	// scraped are series values scraped from targets, by time.
+	scraped        []TargetSample
+	scrapeFailures map[string]int
+	profiles       []ProfileFile
+	errors         []ErrorGroup
+	custom         []CustomMetric
+	checks         []CheckResult
+	slowest        []SlowRequest
+	quality        Quality
+	info           RunInfo
+	// droppedSamples is the number of samples the sink dropped.
+	droppedSamples uint64
+	// rejectedMetrics is the number of user-defined metric values rejected.
+	rejectedMetrics uint64
+}
+
+func (r *Result) Duration() time.Duration {
+	return r.duration
+}
+
+// Requests returns the number of sent requests.
+func (r *Result) Requests() int64 {
+	return r.requests
+}
+
+// Responses returns response counts by code and success.
+func (r *Result) Responses() []Response {
+	res := make([]Response, len(r.responses))
+	copy(res, r.responses)
+
+	return res
+}
+
+// ResponsesCount returns the number of received responses.
+func (r *Result) ResponsesCount() int64 {
+	var n int64
+	for _, resp := range r.responses {
+		n += resp.Count
+	}
+
+	return n
+}
+
+// Errors returns the number of unsuccessful responses.
+func (r *Result) Errors() int64 {
+	var n int64
+	for _, resp := range r.responses {
+		if !resp.Success {
+			n += resp.Count
+		}
+	}
+
+	return n
+}
+
+// ErrorGroups returns errors returned by actors grouped by type and
+// normalized message, the most frequent first.
+func (r *Result) ErrorGroups() []ErrorGroup {
+	res := make([]ErrorGroup, len(r.errors))
+	copy(res, r.errors)
+
+	return res
+}
+
+// ErrorRate returns the share (0..1) of unsuccessful responses.
+func (r *Result) ErrorRate() float64 {
+	n := r.ResponsesCount()
+	if n == 0 {
+		return 0
+	}
+
+	return float64(r.Errors()) / float64(n)
+}
+
+// CodeCount returns the number of responses with the code.
+func (r *Result) CodeCount(code string) int64 {
+	var n int64
+	for _, resp := range r.responses {
+		if resp.Code == code {
+			n += resp.Count
+		}
+	}
+
+	return n
+}
+
+// Throughput returns requests per second.
+func (r *Result) Throughput() float64 {
+	return perSecond(float64(r.requests), r.duration)
+}
+
+func (r *Result) SentBytes() uint64 {
+	return r.sentBytes
+}
+
+func (r *Result) ReceivedBytes() uint64 {
+	return r.receivedBytes
+}
+
+// Traffic returns bytes and connections of the whole run.
+func (r *Result) Traffic() TrafficStats {
+	return r.traffic
+}
+
+// Targets returns bytes and connections per target URI.
+func (r *Result) Targets() []TrafficStats {
+	return r.targets
+}
+
+// Runners returns bytes and connections per runner.
+func (r *Result) Runners() []TrafficStats {
+	return r.runners
+}
+
+// SentByteRate returns sent bytes per second.
+func (r *Result) SentByteRate() float64 {
+	return perSecond(float64(r.sentBytes), r.duration)
+}
+
+// ReceivedByteRate returns received bytes per second.
+func (r *Result) ReceivedByteRate() float64 {
+	return perSecond(float64(r.receivedBytes), r.duration)
+}
+
+func perSecond(v float64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return v / d.Seconds()
+}
+
+// Latency returns the latency percentiles reported by Print.
+func (r *Result) Latency() []LatencyPercentile {
+	res := make([]LatencyPercentile, len(r.latency))
+	copy(res, r.latency)
+
+	return res
+}
+
+// LatencyHistogram returns the latency distribution of all responses.
+func (r *Result) LatencyHistogram() *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.success)
+		h.Merge(r.latencies.failure)
+	}
+
+	return h
+}
+
+// SuccessHistogram returns the latency distribution of successful responses.
+func (r *Result) SuccessHistogram() *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.success)
+	}
+
+	return h
+}
+
+// FailureHistogram returns the latency distribution of unsuccessful responses.
+func (r *Result) FailureHistogram() *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.failure)
+	}
+
+	return h
+}
+
+// TagHistogram returns the latency distribution of requests with the tag.
+func (r *Result) TagHistogram(key, value string) *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.tags[Tag{key, value}])
+	}
+
+	return h
+}
+
+// CodeHistogram returns the latency distribution of responses with the code.
+func (r *Result) CodeHistogram(code string) *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.codes[code])
+	}
+
+	return h
+}
+
+// Tags returns all tags seen during the run.
+func (r *Result) Tags() []Tag {
+	res := make([]Tag, 0)
+	if r.latencies == nil {
+		return res
+	}
+
+	for t := range r.latencies.tags {
+		res = append(res, t)
+	}
+
+	return res
+}
+
+// PhaseHistogram returns the duration distribution of the phase.
+func (r *Result) PhaseHistogram(p Phase) *Histogram {
+	h := NewHistogram()
+	if r.latencies != nil {
+		h.Merge(r.latencies.phases[p])
+	}
+
+	return h
+}
+
+// Percentile returns the latency below which p (0..100) percent of responses fall.
+func (r *Result) Percentile(p float64) time.Duration {
+	return r.LatencyHistogram().Quantile(p / 100)
+}
+
+// SuccessPercentile is Percentile of successful responses.
+func (r *Result) SuccessPercentile(p float64) time.Duration {
+	return r.SuccessHistogram().Quantile(p / 100)
+}
+
+// FailurePercentile is Percentile of unsuccessful responses.
+func (r *Result) FailurePercentile(p float64) time.Duration {
+	return r.FailureHistogram().Quantile(p / 100)
+}
+
+// TagPercentile is Percentile of requests with the tag.
+func (r *Result) TagPercentile(key, value string, p float64) time.Duration {
+	return r.TagHistogram(key, value).Quantile(p / 100)
+}
+
+// CodePercentile is Percentile of responses with the code.
+func (r *Result) CodePercentile(code string, p float64) time.Duration {
+	return r.CodeHistogram(code).Quantile(p / 100)
+}
+
+// Timeline returns per-interval metrics of the run.
+func (r *Result) Timeline() []Interval {
+	return r.timeline
+}
+
+// InflightAvg returns the time-weighted average number of outstanding requests.
+func (r *Result) InflightAvg() float64 {
+	var area float64
+	var d time.Duration
+	for _, i := range r.timeline {
+		area += i.InflightAvg * i.Duration.Seconds()
+		d += i.Duration
+	}
+
+	return perSecond(area, d)
+}
+
+// InflightMax returns the maximum number of outstanding requests.
+func (r *Result) InflightMax() int64 {
+	var n int64
+	for _, i := range r.timeline {
+		n = max(n, i.InflightMax)
+	}
+
+	return n
+}
+
+// Runtime returns the load generator own state sampled during the run.
+func (r *Result) Runtime() []RuntimeSample {
+	return r.runtime
+}
+
+// Warnings returns reasons to distrust the result, e.g. the generator saturation.
+func (r *Result) Warnings() []string {
+	return saturationWarnings(r.runtime, r.duration)
+}
+
+// TargetSamples returns series values scraped from targets during the run, by time.
+func (r *Result) TargetSamples() []TargetSample {
+	return r.scraped
+}
+
+// ScrapeFailures returns the number of failed scrapes by target URL.
+func (r *Result) ScrapeFailures() map[string]int {
+	return r.scrapeFailures
+}
+
+// Profiles returns profiles of the load generator captured during the run.
+func (r *Result) Profiles() []ProfileFile {
+	return r.profiles
+}
+
+// Custom returns user-defined metrics aggregated over the run.
+func (r *Result) Custom() []CustomMetric {
+	return r.custom
+}
+
+// Metric returns the user-defined metric with the name and exactly the tags.
+func (r *Result) Metric(name string, tags ...Tag) (CustomMetric, bool) {
+	key := encodeTags(sortedTags(tags))
+	for _, c := range r.custom {
+		if c.Name == name && encodeTags(c.Tags) == key {
+			return c, true
+		}
+	}
+
+	return CustomMetric{}, false
+}
+
+// Checks returns results of named checks in the order of their first run.
+func (r *Result) Checks() []CheckResult {
+	res := make([]CheckResult, len(r.checks))
+	copy(res, r.checks)
+
+	return res
+}
+
+// Check returns the result of the named check.
+func (r *Result) Check(name string) (CheckResult, bool) {
+	for _, c := range r.checks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return CheckResult{}, false
+}
+
+// DroppedSamples returns the number of samples the sample sink dropped, e.g.
+// while the queue of SampleWriter was full.
+func (r *Result) DroppedSamples() uint64 {
+	return r.droppedSamples
+}
+
+// RejectedMetrics returns the number of user-defined metric values rejected,
+// i.e. not finite numbers or added under a name already used by another kind.
+func (r *Result) RejectedMetrics() uint64 {
+	return r.rejectedMetrics
+}
+
+// Slowest returns the slowest requests of the run, the slowest first.
+func (r *Result) Slowest() []SlowRequest {
+	res := make([]SlowRequest, len(r.slowest))
+	copy(res, r.slowest)
+
+	return res
+}
+
+// RunInfo returns the run metadata.
+func (r *Result) RunInfo() RunInfo {
+	return r.info.copy()
+}
+
+// Quality returns Apdex and SLO compliance of all responses.
+func (r *Result) Quality() Score {
+	return r.scores()[0]
+}
+
+// OperationQuality returns Apdex and SLO compliance per request tag, sorted by name.
+func (r *Result) OperationQuality() []Score {
+	return r.scores()[1:]
+}
+
+func (r *Result) scores() []Score {
+	if r.latencies == nil {
+		return []Score{{}}
+	}
+
+	return qualityScores(r.latencies, r.quality)
+}
+
+func getSpacer(s string, l int) string {
+	if len(s) >= l {
+		return ""
+	}
+
+	b := make([]byte, l)
+	for i := range l {
+		b[i] = '.'
+	}
+
+	return string(b[len(s):])
+}
+
+// Print writes the report to stdout, options add optional sections.
+func (r *Result) Print(opts ...PrintOption) {
+	var o printOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fmt.Println("\nRESULTS:")
+	fmt.Printf("elapsed ....................... %s\n", r.duration)
+	r.printRunInfo()
+
+	if r.requests > 0 {
+		fmt.Println("\nREQUESTS:")
+		fmt.Printf("responses ..................... %d\n", r.ResponsesCount())
+		fmt.Printf("errors ........................ %d\n", r.Errors())
+		fmt.Printf("total ......................... %d\n", r.requests)
+		fmt.Printf("throughput .................... %0.2f %s\n", r.Throughput(), "req/s")
+		fmt.Printf("in-flight ..................... avg %0.2f max %d\n", r.InflightAvg(), r.InflightMax())
+		if r.droppedSamples > 0 {
+			fmt.Printf("dropped samples ............... %d\n", r.droppedSamples)
+		}
+
+		failedRequests := make([]LatencyPercentile, 0)
+		successedRequests := make([]LatencyPercentile, 0)
+		for _, r := range r.latency {
+			if r.Success {
+				successedRequests = append(successedRequests, r)
+			} else {
+				failedRequests = append(failedRequests, r)
+			}
+		}
+
+		if len(successedRequests) > 0 {
+			fmt.Printf("SUCCESSED:\n")
+			for _, p := range successedRequests {
+				fmt.Printf("  latency p(%d) ................. %s\n", p.Percentile, p.Value)
+			}
+		}
+
+		if len(failedRequests) > 0 {
+			fmt.Printf("FAILED:\n")
+			for _, p := range failedRequests {
+				fmt.Printf("  latency p(%d) ................. %s\n", p.Percentile, p.Value)
+			}
+		}
+	}
+
+	r.printQuality()
+
+	phases := make([]Phase, 0)
+	for _, p := range Phases {
+		if r.PhaseHistogram(p).Count() > 0 {
+			phases = append(phases, p)
+		}
+	}
+
+	if len(phases) > 0 {
+		fmt.Println("\nPHASES:")
+		for _, p := range phases {
+			h := r.PhaseHistogram(p)
+			fmt.Printf("%s %s p(50) %s p(99) %s\n", p, getSpacer(string(p), 30), h.Quantile(0.5), h.Quantile(0.99))
+		}
+	}
+
+	if len(r.checks) > 0 {
+		fmt.Println("\nCHECKS:")
+		for _, c := range r.checks {
+			fmt.Printf("%s %s %0.2f%% %d/%d\n", c.Name, getSpacer(c.Name, 30), c.Rate()*100, c.Passes, c.Passes+c.Fails)
+		}
+	}
+
+	fmt.Println("\nCODES:")
+	for _, resp := range r.responses {
+		fmt.Printf("%s %s %d", resp.Code, getSpacer(resp.Code, 30), resp.Count)
+		if h := r.CodeHistogram(resp.Code); h.Count() > 0 {
+			fmt.Printf(" p(50) %s p(99) %s", h.Quantile(0.5), h.Quantile(0.99))
+		}
+		fmt.Println()
+	}
+
+	if o.histogram {
+		r.printHistogram()
+	}
+	if o.heatmap {
+		r.printHeatmap()
+	}
+
+	r.printSlowest()
+	r.printCustom()
+	r.printErrors()
+	r.printRuntime()
+	r.printTargets()
+	r.printProfiles()
+
+	data := r.receivedBytes + r.sentBytes
+	if data > 0 || r.traffic.Connections > 0 || r.traffic.DialFailures > 0 {
+		fmt.Println("\nDATA:")
+		fmt.Printf("sent .......................... %s\n", ByteCountIEC(r.sentBytes))
+		fmt.Printf("received ...................... %s\n", ByteCountIEC(r.receivedBytes))
+		fmt.Printf("total ......................... %s\n", ByteCountIEC(data))
+		fmt.Printf("throughput .................... %s/s\n", ByteCountIEC(uint64(r.SentByteRate()+r.ReceivedByteRate())))
+		r.printConnections()
+	}
+}
+
+func (r *Result) printRunInfo() {
+	info := r.info
+	if !info.Start.IsZero() {
+		fmt.Printf("started ....................... %s\n", info.Start.Format(time.RFC3339))
+	}
+	if info.Hostname != "" {
+		fmt.Printf("host .......................... %s %s GOMAXPROCS %d stinger %s\n",
+			info.Hostname, info.GoVersion, info.GOMAXPROCS, info.Version)
+	}
+	if len(info.Targets) > 0 {
+		fmt.Printf("targets ....................... %s\n", strings.Join(info.Targets, ","))
+	}
+	if info.Revision != "" {
+		fmt.Printf("revision ...................... %s\n", info.Revision)
+	}
+	for _, l := range sortedLabels(info.Labels) {
+		fmt.Printf("%s %s %s\n", l.name, getSpacer(l.name, 30), l.value)
+	}
+}
+
+func (r *Result) printQuality() {
+	scores := r.scores()
+	if scores[0].Total == 0 {
+		return
+	}
+
+	fmt.Println("\nQUALITY:")
+	apdex := fmt.Sprintf("apdex (T=%s)", r.quality.satisfied())
+	slo := fmt.Sprintf("slo (<=%s)", r.quality.target())
+	fmt.Printf("%s %s %0.2f\n", apdex, getSpacer(apdex, 30), scores[0].Apdex())
+	fmt.Printf("%s %s %0.2f%%\n", slo, getSpacer(slo, 30), scores[0].Compliance()*100)
+	for _, s := range scores[1:] {
+		name := "  " + s.Name
+		fmt.Printf("%s %s apdex %0.2f slo %0.2f%%\n", name, getSpacer(name, 30), s.Apdex(), s.Compliance()*100)
+	}
+}
+
+func (r *Result) printConnections() {
+	if r.traffic.Connections == 0 && r.traffic.DialFailures == 0 {
+		return
+	}
+
+	fmt.Printf("connections ................... %d active %d dial failures %d\n",
+		r.traffic.Connections, r.traffic.Active, r.traffic.DialFailures)
+	if h := r.traffic.Lifetime; h != nil && h.Count() > 0 {
+		fmt.Printf("connection lifetime ........... p(50) %s max %s\n", h.Quantile(0.5), h.Max())
+	}
+
+	for _, group := range []struct {
+		name  string
+		stats []TrafficStats
+	}{{"TARGETS", r.targets}, {"RUNNERS", r.runners}} {
+		if len(group.stats) == 0 {
+			continue
+		}
+
+		fmt.Printf("%s:\n", group.name)
+		for _, t := range group.stats {
+			name := "  " + t.Name
+			fmt.Printf("%s %s sent %s received %s connections %d dial failures %d\n", name, getSpacer(name, 30),
+				ByteCountIEC(t.SentBytes), ByteCountIEC(t.ReceivedBytes), t.Connections, t.DialFailures)
+		}
+	}
+}
+
+func (r *Result) printRuntime() {
+	if len(r.runtime) == 0 {
+		return
+	}
+
+	var cpu, maxCPU float64
+	var goroutines, heap uint64
+	var pauses, sched time.Duration
+	for _, s := range r.runtime {
+		cpu += s.CPU
+		maxCPU = max(maxCPU, s.CPU)
+		goroutines = max(goroutines, s.Goroutines)
+		heap = max(heap, s.HeapBytes)
+		pauses += s.GCPause
+		sched = max(sched, s.SchedLatency)
+	}
+
+	fmt.Println("\nGENERATOR:")
+	fmt.Printf("cpu ........................... avg %0.1f%% max %0.1f%%\n", cpu/float64(len(r.runtime))*100, maxCPU*100)
+	fmt.Printf("goroutines .................... max %d\n", goroutines)
+	fmt.Printf("heap .......................... max %s\n", ByteCountIEC(heap))
+	fmt.Printf("gc pauses ..................... %s\n", pauses)
+	fmt.Printf("sched latency p(99) ........... max %s\n", sched)
+
+	for _, w := range r.Warnings() {
+		fmt.Printf("WARNING: %s\n", w)
+	}
+}
+
+func (r *Result) printSlowest() {
+	if len(r.slowest) == 0 {
+		return
+	}
+
+	fmt.Println("\nSLOWEST:")
+	for _, s := range r.slowest {
+		fmt.Printf("%s %s actor %d", s.Latency, s.Start.Format(time.StampMilli), s.Actor)
+		if s.Scenario != "" {
+			fmt.Printf(" %s", s.Scenario)
+		}
+		if s.Target != "" {
+			fmt.Printf(" %s", s.Target)
+		}
+		fmt.Printf(" %s", s.Code)
+		if s.Payload != "" {
+			fmt.Printf(" %s", s.Payload)
+		}
+		fmt.Println()
+	}
+}
+
+func (r *Result) printCustom() {
+	if len(r.custom) == 0 && r.rejectedMetrics == 0 {
+		return
+	}
+
+	fmt.Println("\nMETRICS:")
+	for _, c := range r.custom {
+		name := c.Name
+		if len(c.Tags) > 0 {
+			name += "{" + encodeTags(c.Tags) + "}"
+		}
+
+		var value string
+		switch c.Kind {
+		case CounterMetric, GaugeMetric:
+			value = fmt.Sprintf("%g", c.Value)
+		case RateMetric:
+			value = fmt.Sprintf("%0.2f%% %d/%d", c.Value*100, c.Passes, c.Total)
+		case TrendMetric:
+			value = fmt.Sprintf("avg %g min %g p(50) %g p(90) %g p(99) %g max %g",
+				c.Trend.Mean(), c.Trend.Min(), c.Trend.Quantile(0.5), c.Trend.Quantile(0.9), c.Trend.Quantile(0.99), c.Trend.Max())
+		}
+
+		fmt.Printf("%s %s %s\n", name, getSpacer(name, 30), value)
+	}
+	if r.rejectedMetrics > 0 {
+		fmt.Printf("rejected values ............... %d\n", r.rejectedMetrics)
+	}
+}
+
+func (r *Result) printErrors() {
+	if len(r.errors) == 0 {
+		return
+	}
+
+	fmt.Println("\nERRORS:")
+	for _, g := range r.errors {
+		fmt.Printf("%d x %s\n", g.Count, g.Message)
+		if g.Type != "" {
+			fmt.Printf("  type %s\n", g.Type)
+		}
+		fmt.Printf("  first %s last %s\n", g.First.Format(time.TimeOnly), g.Last.Format(time.TimeOnly))
+		for _, s := range g.Samples {
+			fmt.Printf("  > %s\n", s)
+		}
+	}
+}
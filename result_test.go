@@ -0,0 +1,208 @@
+package stinger
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultAPI(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.AddSentBytes(5)
+	m.StartTimer()
+
+	am := m.forActor(0, "hello")
+	for i := range 10 {
+		_ = am.ObserveRequest(func() (string, bool, error) {
+			am.AddSentBytes(100)
+			if i == 0 {
+				return "Unavailable", false, errors.New("unavailable")
+			}
+
+			return "OK", true, nil
+		}, WithTags(Tag{"method", "SayHello"}))
+	}
+	_ = m.ObserveRequest(func() (string, bool, error) {
+		time.Sleep(10 * time.Millisecond)
+
+		return "OK", true, nil
+	})
+
+	m.StopTimer()
+	r := m.Result()
+
+	assert.Equal(t, int64(11), r.Requests())
+	assert.Equal(t, int64(11), r.ResponsesCount())
+	assert.Equal(t, int64(1), r.Errors())
+	assert.InDelta(t, 1.0/11, r.ErrorRate(), 0.0001)
+	assert.Equal(t, int64(10), r.CodeCount("OK"))
+	assert.Equal(t, int64(1), r.CodeCount("Unavailable"))
+	assert.Equal(t, uint64(1000), r.SentBytes())
+	assert.InDelta(t, 1000/r.Duration().Seconds(), r.SentByteRate(), 0.001)
+	assert.InDelta(t, 11/r.Duration().Seconds(), r.Throughput(), 0.001)
+
+	assert.Equal(t, int64(11), r.LatencyHistogram().Count())
+	assert.Equal(t, int64(10), r.SuccessHistogram().Count())
+	assert.Equal(t, int64(1), r.FailureHistogram().Count())
+	assert.Equal(t, int64(10), r.TagHistogram("method", "SayHello").Count())
+	assert.Equal(t, int64(10), r.TagHistogram(ScenarioTag, "hello").Count())
+	assert.Equal(t, int64(0), r.TagHistogram("method", "unknown").Count())
+	assert.ElementsMatch(t, []Tag{{"method", "SayHello"}, {ScenarioTag, "hello"}}, r.Tags())
+
+	assert.GreaterOrEqual(t, r.Percentile(100), 10*time.Millisecond)
+	assert.GreaterOrEqual(t, r.SuccessPercentile(100), 10*time.Millisecond)
+	assert.Less(t, r.TagPercentile("method", "SayHello", 99), 10*time.Millisecond)
+	assert.Equal(t, r.FailureHistogram().Max(), r.FailurePercentile(50))
+
+	assert.Equal(t, int64(11), r.CodeHistogram("OK").Count()+r.CodeHistogram("Unavailable").Count())
+	assert.Equal(t, r.FailureHistogram().Max(), r.CodePercentile("Unavailable", 99))
+	assert.GreaterOrEqual(t, r.CodePercentile("OK", 100), 10*time.Millisecond)
+	assert.Zero(t, r.CodeHistogram("Internal").Count())
+}
+
+func TestLatenciesMixedScenarios(t *testing.T) {
+	recorded := newLatencies()
+	recorded.record(time.Millisecond, "OK", true, "a", nil)
+	recorded.record(2*time.Millisecond, "Unavailable", false, "a", nil)
+	recorded.record(3*time.Millisecond, "OK", true, "b", nil)
+	recorded.record(4*time.Millisecond, "Unavailable", false, "a", nil)
+
+	l := newLatencies()
+	l.merge(recorded)
+
+	assert.Equal(t, int64(2), l.success.Count())
+	assert.Equal(t, int64(2), l.failure.Count())
+	assert.Equal(t, int64(2), l.codes["Unavailable"].Count())
+	assert.Equal(t, int64(3), l.tags[Tag{ScenarioTag, "a"}].Count())
+	assert.Equal(t, int64(2), l.failedTags[Tag{ScenarioTag, "a"}].Count())
+	assert.Equal(t, int64(1), l.tags[Tag{ScenarioTag, "b"}].Count())
+	assert.Nil(t, l.failedTags[Tag{ScenarioTag, "b"}])
+}
+
+func TestStartRequest(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+
+	r1 := m.StartRequest()
+	r2 := m.StartRequest(WithTags(Tag{"method", "Stream"}))
+	assert.Equal(t, int64(2), m.Inflight())
+
+	err := errors.New("reset")
+	assert.NoError(t, r1.End("OK", true, nil))
+	assert.Equal(t, err, r2.End("Canceled", false, err))
+	assert.NoError(t, r2.End("OK", true, nil), "repeated End must be ignored")
+	assert.Equal(t, int64(0), m.Inflight())
+
+	m.StopTimer()
+	r := m.Result()
+	assert.Equal(t, int64(2), r.ResponsesCount())
+	assert.Equal(t, int64(1), r.TagHistogram("method", "Stream").Count())
+	assert.Equal(t, int64(2), r.InflightMax())
+	assert.Greater(t, r.InflightAvg(), 0.0)
+}
+
+func okRequest() (string, bool, error) {
+	return "OK", true, nil
+}
+
+func TestObserveRequestAllocs(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.StartTimer()
+	am := m.forActor(0, "hello")
+
+	// NOTE: warm up histograms, timeline buckets and the slowest requests
+	for range 1000 {
+		_ = am.ObserveRequest(okRequest)
+	}
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		_ = am.ObserveRequest(okRequest)
+	})
+	assert.Zero(t, allocs)
+}
+
+// observeOverheadBudget is the budget of recording a request besides
+// calling it and reading the clock, in nanoseconds.
+const observeOverheadBudget = 100
+
+// observeBlock is the number of requests timed at once by BenchmarkObserveRequest.
+const observeBlock = 256
+
+func BenchmarkObserveRequest(b *testing.B) {
+	m := newIsolatedMetrics()
+	// NOTE: short intervals reuse buffers of in-flight changes within the round, as long runs do
+	m.SetInterval(10 * time.Millisecond)
+	m.StartTimer()
+
+	var (
+		actors    atomic.Int64
+		mu        sync.Mutex
+		overheads []float64
+		observed  int64
+	)
+	f := okRequest
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	// NOTE: blocks alternate with a baseline doing the same but recording, so both see the same clock and core
+	b.RunParallel(func(pb *testing.PB) {
+		am := m.forActor(int(actors.Add(1)), "hello")
+
+		var baseline time.Duration
+		var local []float64
+		var n int64
+		for k, more := 0, true; more; k ^= 1 {
+			blockStart := time.Now()
+			i := 0
+			for ; i < observeBlock; i++ {
+				if more = pb.Next(); !more {
+					break
+				}
+				if k == 0 {
+					start := time.Now()
+					_, _, _ = f()
+					_ = time.Now().Sub(start)
+				} else {
+					_ = am.ObserveRequest(f)
+				}
+			}
+			elapsed := time.Since(blockStart)
+
+			switch {
+			case k == 0:
+				baseline = elapsed
+			case i == observeBlock:
+				local = append(local, float64(elapsed-baseline)/observeBlock)
+				fallthrough
+			default:
+				n += int64(i)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		overheads = append(overheads, local...)
+		observed += n
+	})
+	b.StopTimer()
+
+	m.StopTimer()
+	assert.Equal(b, observed, m.Result().ResponsesCount())
+	if len(overheads) == 0 {
+		return
+	}
+
+	// NOTE: the median leaves out blocks preempted or merging an interval, which snapshots do
+	slices.Sort(overheads)
+	overhead := overheads[len(overheads)/2]
+	b.ReportMetric(overhead, "overhead-ns/op")
+	// NOTE: short rounds are dominated by the setup
+	if b.N >= 1000000 && overhead > observeOverheadBudget {
+		b.Errorf("recording overhead %.0fns/op of %d requests exceeds %dns/op", overhead, b.N, observeOverheadBudget)
+	}
+}
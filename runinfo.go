@@ -0,0 +1,263 @@
+package stinger
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// modulePath is the import path of stinger, its version is looked up in the build info.
+const modulePath = "github.com/palage4a/stinger"
+
+// RunInfo describes the run and the environment it ran in.
+type RunInfo struct {
+	Start      time.Time `json:"start"`
+	Hostname   string    `json:"hostname"`
+	GoVersion  string    `json:"go_version"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	// Version is the stinger module version, "(devel)" if unknown.
+	Version string `json:"version"`
+	// Targets are URIs of runners and connections observed during the run.
+	Targets []string `json:"targets,omitempty"`
+	// Config is the benchmark configuration as key/value pairs.
+	Config map[string]string `json:"config,omitempty"`
+	// Labels are user-supplied key/value pairs, e.g. the build under test.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Revision is the user-supplied revision, e.g. the git commit of the service.
+	Revision string `json:"revision,omitempty"`
+}
+
+func (i RunInfo) copy() RunInfo {
+	res := i
+	res.Targets = append([]string(nil), i.Targets...)
+	res.Config = copyLabels(i.Config)
+	res.Labels = copyLabels(i.Labels)
+
+	return res
+}
+
+func copyLabels(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+
+	return res
+}
+
+func stingerVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	if bi.Main.Path == modulePath && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	for _, d := range bi.Deps {
+		if d.Path != modulePath {
+			continue
+		}
+		if d.Replace != nil && d.Replace.Version != "" {
+			return d.Replace.Version
+		}
+
+		return d.Version
+	}
+
+	return "(devel)"
+}
+
+// newRunInfo describes the environment, the run itself is described by Benchmark.
+func newRunInfo() RunInfo {
+	hostname, _ := os.Hostname()
+
+	return RunInfo{
+		Hostname:   hostname,
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Version:    stingerVersion(),
+	}
+}
+
+// typeNames returns type names of vs, e.g. configured exporters.
+func typeNames[T any](vs []T) string {
+	names := make([]string, len(vs))
+	for i, v := range vs {
+		names[i] = strings.TrimPrefix(fmt.Sprintf("%T", v), "*")
+	}
+
+	return strings.Join(names, ",")
+}
+
+// configValues renders cfg with defaults applied.
+func configValues(cfg BenchmarkConfig, runners []Runnable) map[string]string {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	exportInterval := cfg.ExportInterval
+	if exportInterval <= 0 {
+		exportInterval = DefaultExportInterval
+	}
+	slowest := cfg.Slowest
+	if slowest <= 0 {
+		slowest = DefaultSlowest
+	}
+
+	scrape := make([]string, len(cfg.Scrape))
+	for i, t := range cfg.Scrape {
+		scrape[i] = t.URL
+	}
+
+	profiles := make([]string, len(cfg.Profile.Kinds))
+	for i, k := range cfg.Profile.Kinds {
+		profiles[i] = string(k)
+	}
+
+	names := make([]string, len(runners))
+	for i, r := range runners {
+		names[i] = fmt.Sprintf("%s(%d)", runnerName(r), r.Parallelism())
+	}
+
+	return map[string]string{
+		"procs":           strconv.Itoa(cfg.Procs),
+		"duration":        cfg.Duration.String(),
+		"verbose":         strconv.FormatBool(cfg.Verbose),
+		"interval":        interval.String(),
+		"export_interval": exportInterval.String(),
+		"exporters":       typeNames(cfg.Exporters),
+		"outputs":         typeNames(cfg.Outputs),
+		"observers":       typeNames(cfg.Observers),
+		"slowest":         strconv.Itoa(slowest),
+		"apdex_satisfied": cfg.Quality.satisfied().String(),
+		"apdex_tolerated": cfg.Quality.tolerated().String(),
+		"slo_target":      cfg.Quality.target().String(),
+		"scrape":          strings.Join(scrape, ","),
+		"dashboard":       strconv.FormatBool(cfg.Dashboard),
+		"profile":         strings.Join(profiles, ","),
+		"runners":         strings.Join(names, ","),
+	}
+}
+
+// describeRun records the configuration of the run started by Benchmark.
+func (m *Metrics) describeRun(cfg BenchmarkConfig, runners []Runnable) {
+	info := newRunInfo()
+	info.Config = configValues(cfg, runners)
+	info.Labels = copyLabels(cfg.Labels)
+	info.Revision = cfg.Revision
+
+	for _, r := range runners {
+		if t, ok := r.(Targeted); ok {
+			info.Targets = append(info.Targets, t.Targets()...)
+		}
+	}
+
+	m.info = info
+}
+
+// RunInfo returns the run metadata. Targets include those connected to.
+func (m *Metrics) RunInfo() RunInfo {
+	res := m.info.copy()
+	res.Start = m.start
+
+	seen := make(map[string]bool)
+	targets := make([]string, 0)
+	for _, t := range res.Targets {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	for _, t := range m.traffic.targetNames() {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	sort.Strings(targets)
+	res.Targets = targets
+
+	return res
+}
+
+// runInfoCollector exposes the run metadata as labels of run_info, which is
+// always 1, and the start time. Label sets depend on user labels, so the
+// collector is unchecked.
+type runInfoCollector struct {
+	m *Metrics
+}
+
+func (c *runInfoCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *runInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	info := c.m.RunInfo()
+
+	labels := runInfoLabels(info)
+	names, values := make([]string, len(labels)), make([]string, len(labels))
+	for i, l := range labels {
+		names[i], values[i] = l.name, l.value
+	}
+
+	desc := prometheus.NewDesc("run_info", "run metadata", names, nil)
+	sendConst(ch)(prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, values...))
+
+	if !info.Start.IsZero() {
+		desc := prometheus.NewDesc("run_start_time_seconds", "run start time", nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(info.Start.UnixNano())/1e9)
+	}
+}
+
+// runInfoLabels flattens the run metadata, user labels and config keys are
+// prefixed with label_ and config_. Keys equal once sanitized keep the first
+// value in key order.
+func runInfoLabels(info RunInfo) []label {
+	res := []label{
+		{"hostname", info.Hostname},
+		{"go_version", info.GoVersion},
+		{"gomaxprocs", strconv.Itoa(info.GOMAXPROCS)},
+		{"version", info.Version},
+		{"revision", info.Revision},
+		{"targets", strings.Join(info.Targets, ",")},
+	}
+
+	seen := make(map[string]bool)
+	for _, prefixed := range []struct {
+		prefix string
+		labels map[string]string
+	}{{"label_", info.Labels}, {"config_", info.Config}} {
+		for _, l := range sortedLabels(prefixed.labels) {
+			name := promName(prefixed.prefix + l.name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			res = append(res, label{name, l.value})
+		}
+	}
+
+	return res
+}
+
+// outputRunLabels returns the run metadata tagging every line of interval
+// outputs: the revision, if any, and user labels.
+func outputRunLabels(info RunInfo) []label {
+	res := sortedLabels(info.Labels)
+	if info.Revision != "" {
+		res = mergeLabels([]label{{"revision", info.Revision}}, res)
+	}
+
+	return res
+}
@@ -0,0 +1,100 @@
+package stinger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type grpcRunner struct {
+	*GrpcBencher
+}
+
+func (r grpcRunner) ActorSetup(context.Context, int) (Actor, error) {
+	return nil, nil
+}
+
+func TestRunInfo(t *testing.T) {
+	m := newIsolatedMetrics()
+	g := grpcRunner{NewGrpcBencher(m, 2, 1, "localhost:1,localhost:2")}
+	m.describeRun(BenchmarkConfig{
+		Duration: time.Minute,
+		Labels:   map[string]string{"build": "1.2.3"},
+		Revision: "abc123",
+	}, []Runnable{g})
+	m.StartTimer()
+	m.ObserveDialFailure("localhost:3")
+	m.StopTimer()
+
+	info := m.Result().RunInfo()
+	assert.Equal(t, m.start, info.Start)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Equal(t, runtime.GOMAXPROCS(0), info.GOMAXPROCS)
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, []string{"localhost:1", "localhost:2", "localhost:3"}, info.Targets)
+	assert.Equal(t, map[string]string{"build": "1.2.3"}, info.Labels)
+	assert.Equal(t, "abc123", info.Revision)
+	assert.Equal(t, "1m0s", info.Config["duration"])
+	assert.Equal(t, "1s", info.Config["interval"])
+	assert.Equal(t, "stinger.grpcRunner(2)", info.Config["runners"])
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	labels := make(map[string]string)
+	for _, f := range families {
+		if f.GetName() != "run_info" {
+			continue
+		}
+		for _, l := range f.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+	}
+	assert.Equal(t, "abc123", labels["revision"])
+	assert.Equal(t, "1.2.3", labels["label_build"])
+	assert.Equal(t, "1m0s", labels["config_duration"])
+	assert.Equal(t, "localhost:1,localhost:2,localhost:3", labels["targets"])
+}
+
+func TestRunInfoLabelsClash(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.describeRun(BenchmarkConfig{
+		Labels:   map[string]string{"a.b": "1", "a_b": "2", "revision": "user"},
+		Revision: "abc123",
+	}, nil)
+	m.StartTimer()
+	m.StopTimer()
+
+	families, err := m.Gatherer().Gather()
+	assert.NoError(t, err)
+
+	labels := make(map[string]string)
+	for _, f := range families {
+		if f.GetName() != "run_info" {
+			continue
+		}
+		for _, l := range f.GetMetric()[0].GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+	}
+	assert.Equal(t, "1", labels["label_a_b"], "the first label in key order must win")
+	assert.Equal(t, "abc123", labels["revision"])
+
+	path := filepath.Join(t.TempDir(), "out.lp")
+	o, err := NewInfluxOutput(InfluxConfig{Path: path, Tags: map[string]string{"a_b": "tag"}})
+	assert.NoError(t, err)
+
+	outputObserver{outputs: []Output{o}}.OnStart(context.Background(), m)
+	assert.NoError(t, o.WriteInterval(context.Background(), testInterval()))
+	assert.NoError(t, o.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "stinger,a.b=1,revision=user,a_b=tag requests=2"), "configured tags must win run labels")
+}
@@ -0,0 +1,411 @@
+package stinger
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sample is a single observed request.
+type Sample struct {
+	Start         time.Time
+	Latency       time.Duration
+	Code          string
+	Success       bool
+	Actor         int
+	Scenario      string
+	SentBytes     uint64
+	ReceivedBytes uint64
+	Tags          []Tag
+}
+
+func (s Sample) End() time.Time {
+	return s.Start.Add(s.Latency)
+}
+
+type SampleSink interface {
+	WriteSample(Sample)
+}
+
+// droppingSink is an optional SampleSink interface counting samples it dropped.
+type droppingSink interface {
+	Dropped() uint64
+}
+
+type sampleRecord struct {
+	Start         int64  `json:"ts"`
+	Latency       int64  `json:"lat"`
+	Code          string `json:"code"`
+	Success       bool   `json:"ok"`
+	Actor         int    `json:"actor"`
+	Scenario      string `json:"scn,omitempty"`
+	SentBytes     uint64 `json:"tx,omitempty"`
+	ReceivedBytes uint64 `json:"rx,omitempty"`
+	Tags          string `json:"tags,omitempty"`
+}
+
+var sampleCSVHeader = []string{"ts", "lat", "code", "ok", "actor", "scn", "tx", "rx", "tags"}
+
+// encodeTags encodes tags as an URL query, keeping their order.
+func encodeTags(tags []Tag) string {
+	var sb strings.Builder
+	for i, t := range tags {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(t.Key))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(t.Value))
+	}
+
+	return sb.String()
+}
+
+func decodeTags(s string) ([]Tag, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	pairs := strings.Split(s, "&")
+	tags := make([]Tag, len(pairs))
+	for i, p := range pairs {
+		k, v, _ := strings.Cut(p, "=")
+
+		var err error
+		if tags[i].Key, err = url.QueryUnescape(k); err != nil {
+			return nil, err
+		}
+		if tags[i].Value, err = url.QueryUnescape(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+func isCSV(path string) bool {
+	return filepath.Ext(path) == ".csv"
+}
+
+// DefaultSampleQueue is the number of samples queued for the file if
+// SampleWriterConfig.Size is zero.
+const DefaultSampleQueue = 1 << 16
+
+type SampleWriterConfig struct {
+	// Path of the log, CSV if it ends with .csv, NDJSON otherwise.
+	Path string
+	// Size of the in-memory queue between actors and the file. Samples are
+	// dropped while it is full, so actors never wait for the file.
+	// DefaultSampleQueue if zero.
+	Size uint
+}
+
+// SampleWriter is a SampleSink writing samples to a file in background.
+type SampleWriter struct {
+	ch   chan Sample
+	wg   *sync.WaitGroup
+	once *sync.Once
+
+	// mu guards ch against sends after Close.
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+
+	f   *os.File
+	w   *bufio.Writer
+	csv *csv.Writer
+	err error
+}
+
+func NewSampleWriter(cfg SampleWriterConfig) (*SampleWriter, error) {
+	if cfg.Size == 0 {
+		cfg.Size = DefaultSampleQueue
+	}
+
+	f, err := os.Create(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("sample writer: create err: %w", err)
+	}
+
+	w := &SampleWriter{
+		ch:   make(chan Sample, cfg.Size),
+		wg:   &sync.WaitGroup{},
+		once: &sync.Once{},
+		f:    f,
+		w:    bufio.NewWriterSize(f, 1<<20),
+	}
+
+	if isCSV(cfg.Path) {
+		w.csv = csv.NewWriter(w.w)
+		if err := w.csv.Write(sampleCSVHeader); err != nil {
+			f.Close()
+
+			return nil, fmt.Errorf("sample writer: write header err: %w", err)
+		}
+	}
+
+	w.wg.Add(1)
+	go w.loop()
+
+	return w, nil
+}
+
+// WriteSample queues s, it is dropped if the queue is full or the writer is closed.
+func (w *SampleWriter) WriteSample(s Sample) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.dropped.Add(1)
+
+		return
+	}
+
+	select {
+	case w.ch <- s:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of samples dropped since the writer was created.
+func (w *SampleWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *SampleWriter) loop() {
+	defer w.wg.Done()
+
+	for s := range w.ch {
+		if w.err != nil {
+			continue
+		}
+
+		w.err = w.write(s)
+	}
+}
+
+func (w *SampleWriter) write(s Sample) error {
+	r := sampleRecord{
+		Start:         s.Start.UnixNano(),
+		Latency:       s.Latency.Nanoseconds(),
+		Code:          s.Code,
+		Success:       s.Success,
+		Actor:         s.Actor,
+		Scenario:      s.Scenario,
+		SentBytes:     s.SentBytes,
+		ReceivedBytes: s.ReceivedBytes,
+		Tags:          encodeTags(s.Tags),
+	}
+
+	if w.csv != nil {
+		return w.csv.Write([]string{
+			strconv.FormatInt(r.Start, 10),
+			strconv.FormatInt(r.Latency, 10),
+			r.Code,
+			strconv.FormatBool(r.Success),
+			strconv.Itoa(r.Actor),
+			r.Scenario,
+			strconv.FormatUint(r.SentBytes, 10),
+			strconv.FormatUint(r.ReceivedBytes, 10),
+			r.Tags,
+		})
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	_, err = w.w.Write(b)
+
+	return err
+}
+
+// Close drains the queue and flushes the log. Samples written after Close are dropped.
+func (w *SampleWriter) Close() error {
+	w.once.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.ch)
+		w.mu.Unlock()
+
+		w.wg.Wait()
+
+		if w.csv != nil {
+			w.csv.Flush()
+			w.err = errors.Join(w.err, w.csv.Error())
+		}
+
+		w.err = errors.Join(w.err, w.w.Flush(), w.f.Close())
+	})
+
+	if w.err != nil {
+		return fmt.Errorf("sample writer: %w", w.err)
+	}
+
+	return nil
+}
+
+type SampleReader struct {
+	r   *bufio.Reader
+	csv *csv.Reader
+}
+
+func NewSampleReader(r io.Reader, isCSV bool) (*SampleReader, error) {
+	sr := &SampleReader{r: bufio.NewReaderSize(r, 1<<20)}
+
+	if isCSV {
+		sr.csv = csv.NewReader(sr.r)
+		sr.csv.FieldsPerRecord = len(sampleCSVHeader)
+		sr.csv.ReuseRecord = true
+
+		if _, err := sr.csv.Read(); err != nil {
+			return nil, fmt.Errorf("sample reader: read header err: %w", err)
+		}
+	}
+
+	return sr, nil
+}
+
+// Read returns the next sample or io.EOF.
+func (r *SampleReader) Read() (Sample, error) {
+	var rec sampleRecord
+
+	if r.csv != nil {
+		row, err := r.csv.Read()
+		if err != nil {
+			return Sample{}, err
+		}
+
+		rec, err = parseSampleRow(row)
+		if err != nil {
+			return Sample{}, fmt.Errorf("sample reader: %w", err)
+		}
+	} else {
+		b, err := r.r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(b) > 0 {
+				err = nil
+			} else {
+				return Sample{}, err
+			}
+		}
+
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return Sample{}, fmt.Errorf("sample reader: unmarshal err: %w", err)
+		}
+	}
+
+	tags, err := decodeTags(rec.Tags)
+	if err != nil {
+		return Sample{}, fmt.Errorf("sample reader: decode tags err: %w", err)
+	}
+
+	return Sample{
+		Start:         time.Unix(0, rec.Start),
+		Latency:       time.Duration(rec.Latency),
+		Code:          rec.Code,
+		Success:       rec.Success,
+		Actor:         rec.Actor,
+		Scenario:      rec.Scenario,
+		SentBytes:     rec.SentBytes,
+		ReceivedBytes: rec.ReceivedBytes,
+		Tags:          tags,
+	}, nil
+}
+
+func parseSampleRow(row []string) (sampleRecord, error) {
+	rec := sampleRecord{Code: row[2], Scenario: row[5], Tags: row[8]}
+
+	var err error
+	if rec.Start, err = strconv.ParseInt(row[0], 10, 64); err != nil {
+		return rec, fmt.Errorf("parse ts err: %w", err)
+	}
+	if rec.Latency, err = strconv.ParseInt(row[1], 10, 64); err != nil {
+		return rec, fmt.Errorf("parse lat err: %w", err)
+	}
+	if rec.Success, err = strconv.ParseBool(row[3]); err != nil {
+		return rec, fmt.Errorf("parse ok err: %w", err)
+	}
+	if rec.Actor, err = strconv.Atoi(row[4]); err != nil {
+		return rec, fmt.Errorf("parse actor err: %w", err)
+	}
+	if rec.SentBytes, err = strconv.ParseUint(row[6], 10, 64); err != nil {
+		return rec, fmt.Errorf("parse tx err: %w", err)
+	}
+	if rec.ReceivedBytes, err = strconv.ParseUint(row[7], 10, 64); err != nil {
+		return rec, fmt.Errorf("parse rx err: %w", err)
+	}
+
+	return rec, nil
+}
+
+// ReadResult rebuilds a Result from a sample log written by SampleWriter.
+func ReadResult(path string, interval time.Duration) (*Result, error) {
+	var start, end time.Time
+	err := readSamples(path, func(s Sample) {
+		if start.IsZero() || s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End().After(end) {
+			end = s.End()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := newIsolatedMetrics()
+	// NOTE: the environment of the reader does not describe the logged run
+	m.info = RunInfo{}
+	m.SetInterval(interval)
+	m.start = start
+	m.recorder.reset(start)
+
+	if err := readSamples(path, m.replay); err != nil {
+		return nil, err
+	}
+	m.duration = end.Sub(start)
+	m.recorder.stop(end)
+
+	return m.Result(), nil
+}
+
+func readSamples(path string, f func(Sample)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("sample reader: open err: %w", err)
+	}
+	defer file.Close()
+
+	r, err := NewSampleReader(file, isCSV(path))
+	if err != nil {
+		return err
+	}
+
+	for {
+		s, err := r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		f(s)
+	}
+}
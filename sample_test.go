@@ -0,0 +1,138 @@
+package stinger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySink struct {
+	samples []Sample
+}
+
+func (s *memorySink) WriteSample(sample Sample) {
+	s.samples = append(s.samples, sample)
+}
+
+func TestObserveRequestSample(t *testing.T) {
+	m := newIsolatedMetrics()
+	sink := &memorySink{}
+	m.SetSampleSink(sink)
+	m.StartTimer()
+
+	am := m.forActor(3, "hello")
+	err := am.ObserveRequest(func() (string, bool, error) {
+		am.AddSentBytes(10)
+		am.AddReceivedBytes(20)
+
+		return "Unavailable", false, errors.New("unavailable")
+	})
+	assert.Error(t, err)
+
+	assert.Len(t, sink.samples, 1)
+	s := sink.samples[0]
+	assert.Equal(t, "Unavailable", s.Code)
+	assert.False(t, s.Success)
+	assert.Equal(t, 3, s.Actor)
+	assert.Equal(t, "hello", s.Scenario)
+	assert.Equal(t, uint64(10), s.SentBytes)
+	assert.Equal(t, uint64(20), s.ReceivedBytes)
+	assert.Positive(t, s.Latency)
+}
+
+func TestSampleWriterDrops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.csv")
+	w, err := NewSampleWriter(SampleWriterConfig{Path: path, Size: 1})
+	assert.NoError(t, err)
+
+	m := newIsolatedMetrics()
+	m.SetSampleSink(w)
+	for range 1000 {
+		w.WriteSample(Sample{Code: "OK", Success: true})
+	}
+	assert.NoError(t, w.Close())
+	w.WriteSample(Sample{Code: "OK", Success: true})
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	r, err := NewSampleReader(f, true)
+	assert.NoError(t, err)
+
+	var written uint64
+	for _, err := r.Read(); err == nil; _, err = r.Read() {
+		written++
+	}
+	assert.Equal(t, uint64(1001), written+w.Dropped(), "samples after Close must be dropped")
+	assert.Equal(t, w.Dropped(), m.Result().DroppedSamples())
+}
+
+func TestSampleWriterDefaultSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.ndjson")
+	w, err := NewSampleWriter(SampleWriterConfig{Path: path})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSampleQueue, cap(w.ch))
+
+	for range 100 {
+		w.WriteSample(Sample{Code: "OK", Success: true})
+	}
+	assert.NoError(t, w.Close())
+	assert.Zero(t, w.Dropped(), "a zero size must not drop every sample")
+
+	res, err := ReadResult(path, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), res.ResponsesCount())
+}
+
+func TestSampleLogResult(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	samples := []Sample{
+		{Start: start, Latency: 10 * time.Millisecond, Code: "OK", Success: true, Actor: 0, Scenario: "a,b", SentBytes: 5, ReceivedBytes: 7},
+		{Start: start.Add(500 * time.Millisecond), Latency: 20 * time.Millisecond, Code: "OK", Success: true, Actor: 1, Tags: []Tag{{"method", "Say&Hello"}, {"k", "="}}},
+		{Start: start.Add(1500 * time.Millisecond), Latency: 500 * time.Millisecond, Code: "DeadlineExceeded", Success: false, Actor: 0},
+	}
+
+	for _, name := range []string{"samples.csv", "samples.ndjson"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+
+			w, err := NewSampleWriter(SampleWriterConfig{Path: path, Size: uint(len(samples))})
+			assert.NoError(t, err)
+			for _, s := range samples {
+				w.WriteSample(s)
+			}
+			assert.NoError(t, w.Close())
+
+			f, err := os.Open(path)
+			assert.NoError(t, err)
+			defer f.Close()
+
+			r, err := NewSampleReader(f, isCSV(path))
+			assert.NoError(t, err)
+			for _, expected := range samples {
+				s, err := r.Read()
+				assert.NoError(t, err)
+				assert.True(t, expected.Start.Equal(s.Start))
+				s.Start = expected.Start
+				assert.Equal(t, expected, s)
+			}
+
+			res, err := ReadResult(path, time.Second)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), res.requests)
+			assert.Equal(t, 2*time.Second, res.duration)
+			assert.Equal(t, uint64(5), res.sentBytes)
+			assert.Equal(t, uint64(7), res.receivedBytes)
+			assert.Len(t, res.Timeline(), 3)
+			assert.Equal(t, int64(2), res.Timeline()[0].Requests)
+			assert.Equal(t, int64(1), res.Timeline()[2].Latency.Count())
+			assert.Equal(t, int64(1), res.TagHistogram("method", "Say&Hello").Count())
+			assert.Equal(t, int64(1), res.TagHistogram(ScenarioTag, "a,b").Count())
+		})
+	}
+}
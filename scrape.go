@@ -0,0 +1,328 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ScrapeTarget is a Prometheus metrics endpoint of the service under test,
+// scraped during Benchmark to see the service side of the load.
+type ScrapeTarget struct {
+	// URL is the endpoint, e.g. http://localhost:9090/metrics.
+	URL string
+	// Series are names of kept metric families, e.g. process_cpu_seconds_total.
+	// Every label set is a series of its own. Counters are kept as per second
+	// rates, gauges and untyped metrics as is, summaries and histograms are skipped.
+	Series []string
+}
+
+// TargetValue is a value of a scraped series.
+type TargetValue struct {
+	Target string
+	// Series is the metric name with labels, e.g. queue_length{queue="jobs"}.
+	Series string
+	Value  float64
+}
+
+// TargetSample is a series value scraped at a time.
+type TargetSample struct {
+	Time time.Time
+	TargetValue
+}
+
+type counterValue struct {
+	time  time.Time
+	value float64
+}
+
+type targetScraper struct {
+	client  *http.Client
+	targets []ScrapeTarget
+
+	mu       sync.Mutex
+	samples  []TargetSample
+	failures map[string]int
+	// counters are previous counter values to compute rates.
+	counters map[string]counterValue
+}
+
+func newTargetScraper() *targetScraper {
+	return &targetScraper{
+		client:   &http.Client{},
+		failures: make(map[string]int),
+		counters: make(map[string]counterValue),
+	}
+}
+
+func (s *targetScraper) reset(targets []ScrapeTarget, timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.client.Timeout = timeout
+	s.targets = targets
+	s.samples = nil
+	s.failures = make(map[string]int)
+	s.counters = make(map[string]counterValue)
+}
+
+// scrape takes a sample of every target.
+func (s *targetScraper) scrape(ctx context.Context) {
+	for _, t := range s.targets {
+		now := time.Now()
+		families, err := s.fetch(ctx, t.URL)
+
+		s.mu.Lock()
+		if err != nil {
+			s.failures[t.URL]++
+		} else {
+			s.record(now, t, families)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *targetScraper) fetch(ctx context.Context, url string) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scrape %s: status %s", url, resp.Status)
+	}
+
+	var p expfmt.TextParser
+
+	return p.TextToMetricFamilies(resp.Body)
+}
+
+func (s *targetScraper) record(now time.Time, t ScrapeTarget, families map[string]*dto.MetricFamily) {
+	for _, name := range t.Series {
+		f, ok := families[name]
+		if !ok {
+			continue
+		}
+
+		for _, metric := range f.GetMetric() {
+			v := TargetValue{Target: t.URL, Series: seriesName(name, metric.GetLabel())}
+
+			switch f.GetType() {
+			case dto.MetricType_GAUGE:
+				v.Value = metric.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				v.Value = metric.GetUntyped().GetValue()
+			case dto.MetricType_COUNTER:
+				key := v.Target + " " + v.Series
+				cur := counterValue{now, metric.GetCounter().GetValue()}
+				prev, seen := s.counters[key]
+				s.counters[key] = cur
+				// NOTE: the first value is a baseline, a drop means the target restarted
+				if !seen || cur.value < prev.value {
+					continue
+				}
+				v.Value = perSecond(cur.value-prev.value, cur.time.Sub(prev.time))
+			default:
+				continue
+			}
+
+			s.samples = append(s.samples, TargetSample{now, v})
+		}
+	}
+}
+
+// seriesName renders the metric name with labels in the exposition format.
+func seriesName(name string, labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = fmt.Sprintf("%s=%q", l.GetName(), l.GetValue())
+	}
+	sort.Strings(pairs)
+
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (s *targetScraper) snapshot() ([]TargetSample, map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := make([]TargetSample, len(s.samples))
+	copy(samples, s.samples)
+
+	failures := make(map[string]int, len(s.failures))
+	for k, v := range s.failures {
+		failures[k] = v
+	}
+
+	return samples, failures
+}
+
+// startScraper scrapes targets every interval until ctx is done. The returned
+// func stops the loop and takes the last sample.
+func startScraper(ctx context.Context, m *Metrics, interval time.Duration, targets []ScrapeTarget) func() {
+	m.scraper.reset(targets, interval)
+	if len(targets) == 0 {
+		return func() {}
+	}
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		m.scraper.scrape(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				m.scraper.scrape(ctx)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		// NOTE: the run context is done by now
+		m.scraper.scrape(context.Background())
+	}
+}
+
+// targetValues sets the last scraped values within every interval, samples
+// at the end of the timeline belong to the last interval.
+func targetValues(timeline []Interval, samples []TargetSample) {
+	// NOTE: samples are appended in time order
+	for j := range timeline {
+		start, end := timeline[j].Start, timeline[j].Start.Add(timeline[j].Duration)
+		last := j == len(timeline)-1
+
+		values := make(map[string]int)
+		from := sort.Search(len(samples), func(k int) bool {
+			return !samples[k].Time.Before(start)
+		})
+		for _, s := range samples[from:] {
+			if s.Time.After(end) || (s.Time.Equal(end) && !last) {
+				break
+			}
+
+			key := s.Target + " " + s.Series
+			if k, ok := values[key]; ok {
+				timeline[j].Target[k] = s.TargetValue
+			} else {
+				values[key] = len(timeline[j].Target)
+				timeline[j].Target = append(timeline[j].Target, s.TargetValue)
+			}
+		}
+	}
+}
+
+// targetSeries returns values of every scraped series along the timeline,
+// an interval without a scrape repeats the previous value.
+func targetSeries(timeline []Interval) ([]TargetValue, [][]float64) {
+	index := make(map[TargetValue]int)
+	keys := make([]TargetValue, 0)
+	for _, i := range timeline {
+		for _, v := range i.Target {
+			k := TargetValue{Target: v.Target, Series: v.Series}
+			if _, ok := index[k]; !ok {
+				index[k] = len(keys)
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	sort.SliceStable(keys, func(a, b int) bool {
+		return keys[a].Target < keys[b].Target
+	})
+	for k, key := range keys {
+		index[key] = k
+	}
+
+	values := make([][]float64, len(keys))
+	for k := range keys {
+		values[k] = make([]float64, len(timeline))
+	}
+	for j, i := range timeline {
+		for k := range keys {
+			if j > 0 {
+				values[k][j] = values[k][j-1]
+			}
+		}
+		for _, v := range i.Target {
+			values[index[TargetValue{Target: v.Target, Series: v.Series}]][j] = v.Value
+		}
+	}
+
+	return keys, values
+}
+
+func (r *Result) printTargets() {
+	keys, values := targetSeries(r.timeline)
+	if len(keys) == 0 && len(r.scrapeFailures) == 0 {
+		return
+	}
+
+	fmt.Println("\nTARGET METRICS:")
+
+	p99 := make([]float64, len(r.timeline))
+	var top time.Duration
+	for j, i := range r.timeline {
+		q := i.Latency.Quantile(0.99)
+		p99[j] = float64(q)
+		top = max(top, q)
+	}
+	name := "latency p(99)"
+	fmt.Printf("%s %s %s max %s\n", name, getSpacer(name, 30), sparkValues(p99), top)
+
+	target := ""
+	for k, key := range keys {
+		if key.Target != target {
+			target = key.Target
+			fmt.Println(target)
+		}
+
+		var sum, top float64
+		for _, v := range values[k] {
+			sum += v
+			top = max(top, v)
+		}
+
+		name := "  " + key.Series
+		fmt.Printf("%s %s %s avg %0.4g max %0.4g\n", name, getSpacer(name, 30), sparkValues(values[k]), sum/float64(len(values[k])), top)
+	}
+
+	failed := make([]string, 0, len(r.scrapeFailures))
+	for t := range r.scrapeFailures {
+		failed = append(failed, t)
+	}
+	sort.Strings(failed)
+	for _, t := range failed {
+		fmt.Printf("WARNING: %d scrapes of %s failed\n", r.scrapeFailures[t], t)
+	}
+}
@@ -0,0 +1,86 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraper(t *testing.T) {
+	var scrapes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := scrapes.Add(1)
+		fmt.Fprintf(w, "# TYPE jobs_total counter\njobs_total %d\n", n*100)
+		fmt.Fprint(w, "# TYPE queue_length gauge\nqueue_length{queue=\"jobs\",shard=\"1\"} 3\n")
+		fmt.Fprint(w, "# TYPE wait_seconds histogram\nwait_seconds_bucket{le=\"+Inf\"} 1\nwait_seconds_sum 1\nwait_seconds_count 1\n")
+	}))
+	defer srv.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	m := newIsolatedMetrics()
+	m.SetInterval(20 * time.Millisecond)
+	m.StartTimer()
+	stop := startScraper(context.Background(), m, 20*time.Millisecond, []ScrapeTarget{
+		{URL: srv.URL, Series: []string{"jobs_total", "queue_length", "wait_seconds", "missing"}},
+		{URL: down.URL, Series: []string{"up"}},
+	})
+	time.Sleep(70 * time.Millisecond)
+	stop()
+	m.StopTimer()
+
+	r := m.Result()
+	assert.Equal(t, map[string]int{down.URL: int(scrapes.Load())}, r.ScrapeFailures())
+
+	series := make(map[string]int)
+	for _, s := range r.TargetSamples() {
+		assert.Equal(t, srv.URL, s.Target)
+		assert.Positive(t, s.Value)
+		series[s.Series]++
+	}
+	// NOTE: the first counter value is a baseline of the rate
+	assert.Equal(t, map[string]int{
+		"jobs_total":                           int(scrapes.Load()) - 1,
+		`queue_length{queue="jobs",shard="1"}`: int(scrapes.Load()),
+	}, series)
+
+	timeline := r.Timeline()
+	require.NotEmpty(t, timeline)
+	assert.Contains(t, timeline[len(timeline)-1].Target, TargetValue{srv.URL, `queue_length{queue="jobs",shard="1"}`, 3})
+}
+
+func TestTargetValues(t *testing.T) {
+	start := time.Now()
+	timeline := []Interval{
+		{Start: start, Duration: time.Second},
+		{Start: start.Add(time.Second), Duration: time.Second},
+		{Start: start.Add(2 * time.Second), Duration: time.Second},
+	}
+
+	sample := func(d time.Duration, series string, v float64) TargetSample {
+		return TargetSample{start.Add(d), TargetValue{"t", series, v}}
+	}
+	targetValues(timeline, []TargetSample{
+		sample(0, "a", 1),
+		sample(500*time.Millisecond, "a", 2),
+		sample(500*time.Millisecond, "b", 5),
+		sample(time.Second, "a", 3),
+		sample(3*time.Second, "a", 4),
+	})
+
+	assert.Equal(t, []TargetValue{{"t", "a", 2}, {"t", "b", 5}}, timeline[0].Target)
+	assert.Equal(t, []TargetValue{{"t", "a", 3}}, timeline[1].Target)
+	assert.Equal(t, []TargetValue{{"t", "a", 4}}, timeline[2].Target)
+
+	keys, values := targetSeries(timeline)
+	assert.Equal(t, []TargetValue{{Target: "t", Series: "a"}, {Target: "t", Series: "b"}}, keys)
+	assert.Equal(t, [][]float64{{2, 3, 4}, {5, 5, 5}}, values)
+}
@@ -0,0 +1,240 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	// saturationCPU is the share of GOMAXPROCS above which the generator is considered busy.
+	saturationCPU = 0.9
+	// saturationSchedLatency is the goroutine scheduling p99 above which requests start late.
+	saturationSchedLatency = time.Millisecond
+	// saturationGCPause is the share of wall time spent in GC pauses.
+	saturationGCPause = 0.05
+)
+
+const (
+	rmGoroutines   = "/sched/goroutines:goroutines"
+	rmHeap         = "/memory/classes/heap/objects:bytes"
+	rmGCPauses     = "/sched/pauses/total/gc:seconds"
+	rmSchedLatency = "/sched/latencies:seconds"
+)
+
+// RuntimeSample is the load generator own state during a sampling period.
+type RuntimeSample struct {
+	Time time.Time
+	// CPU is the used share (0..1) of GOMAXPROCS.
+	CPU        float64
+	Goroutines uint64
+	HeapBytes  uint64
+	// GCPause is the total stop-the-world time within the period.
+	GCPause time.Duration
+	// SchedLatency is p99 of the time goroutines waited to be run.
+	SchedLatency time.Duration
+}
+
+type runtimeMonitor struct {
+	mu      sync.Mutex
+	samples []RuntimeSample
+
+	procs   int
+	last    time.Time
+	lastCPU time.Duration
+	metrics []metrics.Sample
+	prev    []metrics.Sample
+}
+
+func newRuntimeMonitor() *runtimeMonitor {
+	names := []string{rmGoroutines, rmHeap, rmGCPauses, rmSchedLatency}
+
+	mon := &runtimeMonitor{
+		metrics: make([]metrics.Sample, len(names)),
+		prev:    make([]metrics.Sample, len(names)),
+	}
+	for i, n := range names {
+		mon.metrics[i].Name = n
+		mon.prev[i].Name = n
+	}
+
+	return mon
+}
+
+func (mon *runtimeMonitor) reset() {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	mon.samples = nil
+	mon.procs = runtime.GOMAXPROCS(0)
+	mon.last = time.Now()
+	mon.lastCPU = cpuTime()
+	metrics.Read(mon.prev)
+}
+
+// sample records the state since the previous sample.
+func (mon *runtimeMonitor) sample() {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	now, cpu := time.Now(), cpuTime()
+	metrics.Read(mon.metrics)
+	cur, prev := mon.metrics, mon.prev
+
+	s := RuntimeSample{
+		Time:       now,
+		Goroutines: cur[0].Value.Uint64(),
+		HeapBytes:  cur[1].Value.Uint64(),
+	}
+	if wall := now.Sub(mon.last); wall > 0 {
+		s.CPU = min(float64(cpu-mon.lastCPU)/(float64(wall)*float64(mon.procs)), 1)
+	}
+
+	pauses := histogramDelta(cur[2].Value.Float64Histogram(), prev[2].Value.Float64Histogram())
+	s.GCPause = time.Duration(pauses.sum() * float64(time.Second))
+	s.SchedLatency = time.Duration(histogramDelta(
+		cur[3].Value.Float64Histogram(), prev[3].Value.Float64Histogram(),
+	).quantile(0.99) * float64(time.Second))
+
+	mon.samples = append(mon.samples, s)
+	mon.last, mon.lastCPU = now, cpu
+	mon.metrics, mon.prev = mon.prev, mon.metrics
+}
+
+func (mon *runtimeMonitor) snapshot() []RuntimeSample {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	res := make([]RuntimeSample, len(mon.samples))
+	copy(res, mon.samples)
+
+	return res
+}
+
+type runtimeHistogram struct {
+	counts  []uint64
+	buckets []float64
+}
+
+func histogramDelta(cur, prev *metrics.Float64Histogram) runtimeHistogram {
+	h := runtimeHistogram{counts: make([]uint64, len(cur.Counts)), buckets: cur.Buckets}
+	for i, c := range cur.Counts {
+		h.counts[i] = c
+		if prev != nil && i < len(prev.Counts) {
+			h.counts[i] -= prev.Counts[i]
+		}
+	}
+
+	return h
+}
+
+// value returns a representative value of the bucket i, minding infinite edges.
+func (h runtimeHistogram) value(i int) float64 {
+	low, high := h.buckets[i], h.buckets[i+1]
+	switch {
+	case math.IsInf(low, -1):
+		return high
+	case math.IsInf(high, 1):
+		return low
+	default:
+		return low + (high-low)/2
+	}
+}
+
+func (h runtimeHistogram) sum() float64 {
+	var s float64
+	for i, c := range h.counts {
+		s += float64(c) * h.value(i)
+	}
+
+	return s
+}
+
+func (h runtimeHistogram) quantile(q float64) float64 {
+	var total uint64
+	for _, c := range h.counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return h.value(i)
+		}
+	}
+
+	return 0
+}
+
+// startSelfMonitor samples the runtime every interval until ctx is done. The
+// returned func stops the loop and takes the last sample.
+func startSelfMonitor(ctx context.Context, m *Metrics, interval time.Duration) func() {
+	m.runtime.reset()
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				m.runtime.sample()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+
+		m.runtime.sample()
+	}
+}
+
+// saturationWarnings explains why the generator may have been the bottleneck.
+func saturationWarnings(samples []RuntimeSample, d time.Duration) []string {
+	res := make([]string, 0)
+	if len(samples) == 0 {
+		return res
+	}
+
+	var maxCPU float64
+	var maxSched, pauses time.Duration
+	for _, s := range samples {
+		maxCPU = max(maxCPU, s.CPU)
+		maxSched = max(maxSched, s.SchedLatency)
+		pauses += s.GCPause
+	}
+
+	if maxCPU >= saturationCPU {
+		res = append(res, fmt.Sprintf("generator CPU usage reached %.0f%% of GOMAXPROCS", maxCPU*100))
+	}
+	if maxSched > saturationSchedLatency {
+		res = append(res, fmt.Sprintf("goroutine scheduling latency p99 reached %s", maxSched))
+	}
+
+	if d > 0 && float64(pauses)/float64(d) > saturationGCPause {
+		res = append(res, fmt.Sprintf("GC pauses took %.1f%% of the run", float64(pauses)/float64(d)*100))
+	}
+
+	return res
+}
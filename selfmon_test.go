@@ -0,0 +1,44 @@
+package stinger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfMonitor(t *testing.T) {
+	m := newIsolatedMetrics()
+
+	stop := startSelfMonitor(context.Background(), m, 10*time.Millisecond)
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) { //nolint:revive
+		// NOTE: burn CPU to be seen by the monitor
+	}
+	stop()
+
+	samples := m.runtime.snapshot()
+	assert.GreaterOrEqual(t, len(samples), 2)
+	for _, s := range samples {
+		assert.Positive(t, s.Goroutines)
+		assert.Positive(t, s.HeapBytes)
+		assert.GreaterOrEqual(t, s.CPU, 0.0)
+		assert.LessOrEqual(t, s.CPU, 1.0)
+	}
+}
+
+func TestSaturationWarnings(t *testing.T) {
+	assert.Empty(t, saturationWarnings(nil, time.Second))
+	assert.Empty(t, saturationWarnings([]RuntimeSample{{CPU: 0.5}}, time.Second))
+
+	warnings := saturationWarnings([]RuntimeSample{
+		{CPU: 0.5, GCPause: 50 * time.Millisecond},
+		{CPU: 0.95, SchedLatency: 5 * time.Millisecond, GCPause: 50 * time.Millisecond},
+	}, time.Second)
+	assert.Equal(t, []string{
+		"generator CPU usage reached 95% of GOMAXPROCS",
+		"goroutine scheduling latency p99 reached 5ms",
+		"GC pauses took 10.0% of the run",
+	}, warnings)
+}
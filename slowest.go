@@ -0,0 +1,128 @@
+package stinger
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowest is the number of the slowest requests kept in Result.
+const DefaultSlowest = 10
+
+// SlowRequest describes one of the slowest requests of the run.
+type SlowRequest struct {
+	Start    time.Time
+	Latency  time.Duration
+	Actor    int
+	Scenario string
+	Target   string
+	Code     string
+	Success  bool
+	// Payload is the user-supplied request description.
+	Payload string
+	Tags    []Tag
+}
+
+// WithTarget names the URI the request is sent to.
+func WithTarget(uri string) RequestOption {
+	return func(r *requestInfo) {
+		r.target = uri
+	}
+}
+
+// WithPayload describes the request payload, it is kept only for the slowest requests.
+func WithPayload(desc string) RequestOption {
+	return func(r *requestInfo) {
+		r.payload = desc
+	}
+}
+
+// slowHeap is a min-heap of requests by latency.
+type slowHeap []SlowRequest
+
+func (h slowHeap) Len() int           { return len(h) }
+func (h slowHeap) Less(i, j int) bool { return h[i].Latency < h[j].Latency }
+func (h slowHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x any)        { *h = append(*h, x.(SlowRequest)) }
+
+func (h *slowHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// slowest keeps the n slowest requests.
+type slowest struct {
+	mu   sync.Mutex
+	n    int
+	heap slowHeap
+	// floor is the fastest kept latency once full, faster requests skip the lock.
+	floor atomic.Int64
+}
+
+func newSlowest(n int) *slowest {
+	return &slowest{n: n}
+}
+
+func (s *slowest) reset(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.n = n
+	s.heap = nil
+	s.floor.Store(0)
+}
+
+// keeps reports whether a request of latency d may be kept.
+func (s *slowest) keeps(d time.Duration) bool {
+	return int64(d) > s.floor.Load()
+}
+
+func (s *slowest) record(r SlowRequest) {
+	if !s.keeps(r.Latency) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.n <= 0 {
+		return
+	}
+
+	if len(s.heap) < s.n {
+		heap.Push(&s.heap, r)
+	} else if r.Latency > s.heap[0].Latency {
+		s.heap[0] = r
+		heap.Fix(&s.heap, 0)
+	}
+
+	if len(s.heap) == s.n {
+		s.floor.Store(int64(s.heap[0].Latency))
+	}
+}
+
+// snapshot returns the kept requests, the slowest first.
+func (s *slowest) snapshot() []SlowRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]SlowRequest, len(s.heap))
+	copy(res, s.heap)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Latency > res[j].Latency
+	})
+
+	return res
+}
+
+// SetSlowest sets the number of the slowest requests kept. Must be called before StartTimer.
+func (m *Metrics) SetSlowest(n int) {
+	if n > 0 {
+		m.slowest.reset(n)
+	}
+}
@@ -0,0 +1,47 @@
+package stinger
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowest(t *testing.T) {
+	s := newSlowest(3)
+	for _, i := range rand.Perm(100) {
+		s.record(SlowRequest{Latency: time.Duration(i), Actor: i})
+	}
+
+	res := s.snapshot()
+	assert.Len(t, res, 3)
+	for i, r := range res {
+		assert.Equal(t, time.Duration(99-i), r.Latency)
+		assert.Equal(t, 99-i, r.Actor)
+	}
+}
+
+func TestObserveRequestSlowest(t *testing.T) {
+	m := newIsolatedMetrics()
+	m.SetSlowest(1)
+
+	am := m.forActor(3, "hello")
+	_ = am.ObserveRequest(func() (string, bool, error) {
+		return "OK", true, nil
+	}, WithTarget("localhost:1"))
+	_ = am.ObserveRequest(func() (string, bool, error) {
+		time.Sleep(5 * time.Millisecond)
+
+		return "DeadlineExceeded", false, nil
+	}, WithTarget("localhost:2"), WithPayload("name=Ivan"))
+
+	res := m.Result().Slowest()
+	assert.Len(t, res, 1)
+	assert.Equal(t, "localhost:2", res[0].Target)
+	assert.Equal(t, "name=Ivan", res[0].Payload)
+	assert.Equal(t, 3, res[0].Actor)
+	assert.Equal(t, "hello", res[0].Scenario)
+	assert.Equal(t, "DeadlineExceeded", res[0].Code)
+	assert.GreaterOrEqual(t, res[0].Latency, 5*time.Millisecond)
+}
@@ -0,0 +1,133 @@
+package stinger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// statsdPacketSize keeps datagrams within a typical MTU.
+const statsdPacketSize = 1432
+
+type StatsDConfig struct {
+	// Addr is host:port of the UDP listener.
+	Addr string
+	// Prefix is "stinger" if empty.
+	Prefix string
+	// Tags are added to every metric. Setting them, or DogStatsD, switches
+	// to DogStatsD format, where labels are sent as tags instead of path parts.
+	Tags      map[string]string
+	DogStatsD bool
+}
+
+// StatsDOutput sends counts of every interval as counters and everything else
+// as gauges. Run labels and the revision are tags, so plain StatsD format,
+// which has none, goes without them.
+type StatsDOutput struct {
+	cfg  StatsDConfig
+	tags []label
+
+	mu   *sync.Mutex
+	conn net.Conn
+}
+
+func NewStatsDOutput(cfg StatsDConfig) (*StatsDOutput, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "stinger"
+	}
+	if len(cfg.Tags) > 0 {
+		cfg.DogStatsD = true
+	}
+
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: dial err: %w", err)
+	}
+
+	return &StatsDOutput{cfg: cfg, tags: sortedLabels(cfg.Tags), mu: &sync.Mutex{}, conn: conn}, nil
+}
+
+// setRunLabels adds run labels to the tags, configured tags win.
+func (o *StatsDOutput) setRunLabels(labels []label) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.tags = mergeLabels(labels, sortedLabels(o.cfg.Tags))
+}
+
+func (o *StatsDOutput) WriteInterval(_ context.Context, i Interval) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	packet := make([]byte, 0, statsdPacketSize)
+	for _, p := range intervalPoints(i) {
+		line := o.line(p)
+		if len(packet)+len(line) > statsdPacketSize && len(packet) > 0 {
+			if _, err := o.conn.Write(packet[:len(packet)-1]); err != nil {
+				return fmt.Errorf("statsd: write err: %w", err)
+			}
+			packet = packet[:0]
+		}
+
+		packet = append(packet, line...)
+		packet = append(packet, '\n')
+	}
+
+	if len(packet) > 0 {
+		if _, err := o.conn.Write(packet[:len(packet)-1]); err != nil {
+			return fmt.Errorf("statsd: write err: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// line renders the point. A signed gauge value is a change of the gauge in
+// StatsD, so negative gauges are reset to zero first, within the same packet.
+func (o *StatsDOutput) line(p point) []byte {
+	if !p.counter && p.value < 0 {
+		zero := p
+		zero.value = 0
+
+		return append(append(o.line(zero), '\n'), o.metric(p)...)
+	}
+
+	return o.metric(p)
+}
+
+func (o *StatsDOutput) metric(p point) []byte {
+	var b []byte
+
+	var tags []label
+	if o.cfg.DogStatsD {
+		b = append(b, o.cfg.Prefix+"."+p.name...)
+		tags = mergeLabels(o.tags, p.labels)
+	} else {
+		b = append(b, flatName(o.cfg.Prefix, p)...)
+	}
+
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, p.value, 'f', -1, 64)
+	if p.counter {
+		b = append(b, "|c"...)
+	} else {
+		b = append(b, "|g"...)
+	}
+
+	for j, t := range tags {
+		if j == 0 {
+			b = append(b, "|#"...)
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, t.name+":"+t.value...)
+	}
+
+	return b
+}
+
+func (o *StatsDOutput) Close() error {
+	return o.conn.Close()
+}
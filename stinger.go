@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,6 +23,34 @@ type Runnable interface {
 	ActorSetup(context.Context, int) (Actor, error)
 }
 
+// Named is an optional Runnable interface naming its scenario in samples and reports.
+type Named interface {
+	Name() string
+}
+
+func runnerName(r Runnable) string {
+	if n, ok := r.(Named); ok {
+		return n.Name()
+	}
+
+	return strings.TrimPrefix(fmt.Sprintf("%T", r), "*")
+}
+
+// Targeted is an optional Runnable interface listing URIs it sends requests to.
+type Targeted interface {
+	Targets() []string
+}
+
+type metricsKey struct{}
+
+// MetricsFromContext returns the actor metrics handle passed by Benchmark to
+// ActorSetup, or nil. Connections dialed with it attribute traffic to the actor.
+func MetricsFromContext(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(metricsKey{}).(*Metrics)
+
+	return m
+}
+
 type BaseGenerator interface {
 	Generate()
 	Wait(bool)
@@ -35,6 +64,33 @@ type BenchmarkConfig struct {
 	Procs    int
 	Duration time.Duration
 	Verbose  bool
+	// Interval is the width of Result timeline intervals, DefaultInterval if zero.
+	Interval time.Duration
+
+	Exporters []Exporter
+	// ExportInterval is the period of exports during the run, DefaultExportInterval if zero.
+	ExportInterval time.Duration
+	// Outputs receive every timeline interval once it is complete.
+	Outputs []Output
+	// Slowest is the number of the slowest requests kept, DefaultSlowest if zero.
+	Slowest int
+	// Quality sets thresholds of Apdex and SLO compliance scores.
+	Quality Quality
+	// Scrape are metrics endpoints of the service under test scraped every
+	// Interval, kept series are added to the Result timeline.
+	Scrape []ScrapeTarget
+	// Profile captures profiles of the load generator, their files are listed in Result.
+	Profile ProfileConfig
+	// Observers are notified of the run lifecycle after the built-in ones.
+	Observers []Observer
+	// Dashboard renders live metrics to stdout, full screen on a terminal
+	// and a status line per interval otherwise.
+	Dashboard bool
+
+	// Labels are recorded in Result run metadata and exported, e.g. the build under test.
+	Labels map[string]string
+	// Revision is recorded in Result run metadata and exported, e.g. the git commit of the service.
+	Revision string
 }
 
 func Benchmark(ctx context.Context, m *Metrics, cfg BenchmarkConfig, runners ...Runnable) *Result {
@@ -44,19 +100,35 @@ func Benchmark(ctx context.Context, m *Metrics, cfg BenchmarkConfig, runners ...
 	gCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
 	defer cancel()
 
+	obs := newObserverSet(cfg)
+	m.describeRun(cfg, runners)
+	m.SetInterval(cfg.Interval)
+	m.SetSlowest(cfg.Slowest)
+	m.SetQuality(cfg.Quality)
+	obs.start(ctx, m)
+
+	obs.stageChange(ctx, StageSetUp)
 	for _, r := range runners {
 		r.SetUp(ctx)
 	}
 
 	m.StartTimer()
+	obs.stageChange(gCtx, StageRun)
+	stopSnapshots := startSnapshots(ctx, gCtx, m, obs)
+	stopSelfMonitor := startSelfMonitor(gCtx, m, m.recorder.interval())
+	stopScraper := startScraper(gCtx, m, m.recorder.interval(), cfg.Scrape)
+	stopProfiling := startProfiling(gCtx, m, cfg.Profile)
 	for _, r := range runners {
+		scenario := runnerName(r)
 		for i := range r.Parallelism() {
 			wg.Add(1)
 			go func(ctx context.Context) {
 				defer wg.Done()
 
-				actor, err := r.ActorSetup(ctx, i)
+				am := m.forActor(i, scenario)
+				actor, err := r.ActorSetup(context.WithValue(ctx, metricsKey{}, am), i)
 				if err != nil {
+					obs.abort(ctx, err)
 					fatal(err)
 				}
 
@@ -67,24 +139,29 @@ func Benchmark(ctx context.Context, m *Metrics, cfg BenchmarkConfig, runners ...
 					default:
 					}
 
-					err := actor.Run(m)
+					err := actor.Run(am)
 					if err != nil {
 						if errors.Is(err, ErrEndOfData) {
 							return
 						}
 
-						if cfg.Verbose {
-							fmt.Printf("run err: %s\n", err)
-						}
+						obs.actorError(ctx, ActorError{i, scenario, err, am.observeError(err)})
 					}
 				}
 			}(gCtx)
 		}
 	}
 	wg.Wait()
+	stopSelfMonitor()
+	stopScraper()
+	stopProfiling()
 	m.StopTimer()
+	stopSnapshots()
+
+	r := m.Result()
+	obs.finish(finalContext(ctx), r)
 
-	return m.Result()
+	return r
 }
 
 func fatal(a ...any) {
@@ -0,0 +1,690 @@
+package stinger
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultInterval = time.Second
+
+// Interval holds everything observed during a single time slot of the run.
+type Interval struct {
+	Start         time.Time
+	Duration      time.Duration
+	Requests      int64
+	Responses     []Response
+	SentBytes     uint64
+	ReceivedBytes uint64
+	Latency       *Histogram
+	// Custom are user-defined metrics aggregated within the interval.
+	Custom []CustomMetric
+	// InflightMin, InflightAvg and InflightMax describe outstanding requests
+	// within the interval, the average is weighted by time.
+	InflightMin int64
+	InflightAvg float64
+	InflightMax int64
+	// Quality rates responses received within the interval.
+	Quality Score
+	// Target are the last values of series scraped from targets within the interval.
+	Target []TargetValue
+
+	// failures is the unsuccessful part of Latency.
+	failures *Histogram
+}
+
+// Throughput returns completed responses per second within the interval.
+func (i Interval) Throughput() float64 {
+	if i.Duration <= 0 {
+		return 0
+	}
+
+	var n int64
+	for _, r := range i.Responses {
+		n += r.Count
+	}
+
+	return float64(n) / i.Duration.Seconds()
+}
+
+type responseKey struct {
+	code    string
+	success bool
+}
+
+// responseCounts counts responses by key. The counter of the last key is
+// reached without the map, as hashing codes is most of recording a response.
+// It is not safe for concurrent use.
+type responseCounts struct {
+	counts map[responseKey]*int64
+	last   responseKey
+	lastN  *int64
+}
+
+func newResponseCounts() *responseCounts {
+	return &responseCounts{counts: make(map[responseKey]*int64)}
+}
+
+func (c *responseCounts) add(key responseKey, n int64) {
+	if c.lastN == nil || key != c.last {
+		p, ok := c.counts[key]
+		if !ok {
+			p = new(int64)
+			c.counts[key] = p
+		}
+		c.last, c.lastN = key, p
+	}
+
+	*c.lastN += n
+}
+
+// merge adds counts of o.
+func (c *responseCounts) merge(o *responseCounts) {
+	for k, n := range o.counts {
+		c.add(k, *n)
+	}
+}
+
+// addTo adds the counts to m.
+func (c *responseCounts) addTo(m map[responseKey]int64) {
+	for k, n := range c.counts {
+		m[k] += *n
+	}
+}
+
+func (c *responseCounts) sorted() []Response {
+	m := make(map[responseKey]int64, len(c.counts))
+	c.addTo(m)
+
+	return sortedResponses(m)
+}
+
+// inflightChange is a change of outstanding requests of a shard by one. It
+// holds twice the time since the run start, plus one for an increment, so a
+// request costs two words.
+type inflightChange int64
+
+func makeInflightChange(at time.Duration, delta int64) inflightChange {
+	c := inflightChange(at) << 1
+	if delta > 0 {
+		c |= 1
+	}
+
+	return c
+}
+
+func (c inflightChange) at() time.Duration {
+	return time.Duration(c >> 1)
+}
+
+func (c inflightChange) delta() int64 {
+	if c&1 != 0 {
+		return 1
+	}
+
+	return -1
+}
+
+// inflightRun is a shard changes within a bucket, in time order. They are
+// kept in chunks, so that recording never copies them.
+type inflightRun struct {
+	full [][]inflightChange
+	tail []inflightChange
+}
+
+// inflightChunk is the number of changes of a chunk.
+const inflightChunk = 1024
+
+// inflightChunks keeps chunks of settled buckets, as a shard changes the
+// number twice per request. Unlike sync.Pool, it keeps them over garbage
+// collections, so a steady run does not allocate.
+var inflightChunks struct {
+	mu   sync.Mutex
+	free [][]inflightChange
+}
+
+func newInflightChunk() []inflightChange {
+	inflightChunks.mu.Lock()
+	defer inflightChunks.mu.Unlock()
+
+	if n := len(inflightChunks.free); n > 0 {
+		c := inflightChunks.free[n-1]
+		inflightChunks.free = inflightChunks.free[:n-1]
+
+		return c
+	}
+
+	return make([]inflightChange, 0, inflightChunk)
+}
+
+func releaseInflightChunks(chunks [][]inflightChange) {
+	inflightChunks.mu.Lock()
+	defer inflightChunks.mu.Unlock()
+
+	for _, c := range chunks {
+		if cap(c) == inflightChunk {
+			inflightChunks.free = append(inflightChunks.free, c[:0])
+		}
+	}
+}
+
+// add appends c and moves it back past later changes.
+func (r *inflightRun) add(c inflightChange) {
+	// NOTE: changes mostly come in time order
+	if n := len(r.tail); n > 0 && n < inflightChunk && r.tail[n-1].at() <= c.at() {
+		r.tail = append(r.tail, c)
+
+		return
+	}
+
+	if len(r.tail) == inflightChunk {
+		r.full, r.tail = append(r.full, r.tail), nil
+	}
+	if r.tail == nil {
+		r.tail = newInflightChunk()
+	}
+	r.tail = append(r.tail, c)
+
+	// NOTE: synchronous requests are recorded on completion, so their start comes late
+	for i := len(r.full)*inflightChunk + len(r.tail) - 1; i > 0; i-- {
+		p, q := r.change(i-1), r.change(i)
+		if p.at() <= c.at() {
+			break
+		}
+		*p, *q = *q, *p
+	}
+}
+
+func (r *inflightRun) change(i int) *inflightChange {
+	if j := i / inflightChunk; j < len(r.full) {
+		return &r.full[j][i%inflightChunk]
+	}
+
+	return &r.tail[i%inflightChunk]
+}
+
+// chunks returns the chunks of changes, the run must not be changed after.
+func (r *inflightRun) chunks() [][]inflightChange {
+	if len(r.tail) == 0 {
+		return r.full
+	}
+
+	return append(r.full, r.tail)
+}
+
+// copy returns a copy in chunks of its own.
+func (r *inflightRun) copy() inflightRun {
+	res := inflightRun{full: make([][]inflightChange, len(r.full))}
+	for i, c := range r.full {
+		res.full[i] = append(newInflightChunk(), c...)
+	}
+	if r.tail != nil {
+		res.tail = append(newInflightChunk(), r.tail...)
+	}
+
+	return res
+}
+
+// inflightRuns is a min-heap of chunks of runs by the first change.
+type inflightRuns [][][]inflightChange
+
+func (h inflightRuns) Len() int           { return len(h) }
+func (h inflightRuns) Less(i, j int) bool { return h[i][0][0].at() < h[j][0][0].at() }
+func (h inflightRuns) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *inflightRuns) Push(x any)        { *h = append(*h, x.([][]inflightChange)) }
+
+func (h *inflightRuns) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+type bucket struct {
+	requests  int64
+	responses *responseCounts
+	sent      uint64
+	received  uint64
+	latency   *Histogram
+	failures  *Histogram
+	custom    customSet
+
+	// inflightStart is the number of outstanding requests at the bucket start.
+	inflightStart int64
+	// inflightChanges are changes of the shard within the bucket.
+	inflightChanges inflightRun
+	// inflightRuns are changes of merged shards, kept till settleInflight.
+	inflightRuns inflightRuns
+	// inflightMin and inflightMax are the run-wide extremes, see settleInflight.
+	inflightMin int64
+	inflightMax int64
+	// inflightArea is the integral of outstanding requests over time, in nanoseconds.
+	inflightArea float64
+}
+
+func newBucket() *bucket {
+	return &bucket{
+		responses: newResponseCounts(),
+		latency:   NewHistogram(),
+		failures:  NewHistogram(),
+		custom:    make(customSet),
+	}
+}
+
+func (b *bucket) changeInflight(at time.Duration, delta int64) {
+	b.inflightChanges.add(makeInflightChange(at, delta))
+}
+
+// settleInflight computes extremes of the bucket starting at from once
+// buckets of all shards are merged. The run-wide number is summed from
+// shard changes in time order, then changes are dropped.
+func (b *bucket) settleInflight(from time.Duration) {
+	n, at := b.inflightStart, from
+	b.inflightMin, b.inflightMax = math.MaxInt64, math.MinInt64
+	observe := func() {
+		b.inflightMin = min(b.inflightMin, n)
+		b.inflightMax = max(b.inflightMax, n)
+	}
+	apply := func(c inflightChange) {
+		// NOTE: the number holds till the next change, simultaneous changes are a single one
+		if c.at() > at {
+			observe()
+			at = c.at()
+		}
+		n += c.delta()
+	}
+
+	runs := b.inflightRuns
+	if c := b.inflightChanges.chunks(); len(c) > 0 {
+		runs = append(runs, c)
+	}
+	for _, r := range runs {
+		defer releaseInflightChunks(r)
+	}
+
+	if len(runs) == 1 {
+		for _, c := range runs[0] {
+			for _, x := range c {
+				apply(x)
+			}
+		}
+	} else {
+		walk := make(inflightRuns, len(runs))
+		for i, r := range runs {
+			walk[i] = slices.Clone(r)
+		}
+		heap.Init(&walk)
+		for len(walk) > 0 {
+			r := &walk[0]
+			apply((*r)[0][0])
+			if (*r)[0] = (*r)[0][1:]; len((*r)[0]) == 0 {
+				*r = (*r)[1:]
+			}
+			if len(*r) == 0 {
+				heap.Pop(&walk)
+			} else {
+				heap.Fix(&walk, 0)
+			}
+		}
+	}
+	observe()
+
+	b.inflightChanges, b.inflightRuns = inflightRun{}, nil
+}
+
+// merge adds o recorded by another shard over the same time slot.
+func (b *bucket) merge(o *bucket) {
+	b.requests += o.requests
+	b.responses.merge(o.responses)
+	b.sent += o.sent
+	b.received += o.received
+	b.latency.Merge(o.latency)
+	b.failures.Merge(o.failures)
+	b.custom.merge(o.custom)
+
+	b.inflightStart += o.inflightStart
+	b.inflightArea += o.inflightArea
+	if c := o.inflightChanges.chunks(); len(c) > 0 {
+		b.inflightRuns = append(b.inflightRuns, c)
+	}
+	b.inflightRuns = append(b.inflightRuns, o.inflightRuns...)
+}
+
+// copy returns a deep copy of a shard bucket.
+func (b *bucket) copy() *bucket {
+	res := newBucket()
+	res.merge(b)
+	// NOTE: the shard goes on changing its changes
+	res.inflightRuns = nil
+	res.inflightChanges = b.inflightChanges.copy()
+
+	return res
+}
+
+// toInterval converts a merged bucket of the time slot [start, start+d).
+func (b *bucket) toInterval(start time.Time, d time.Duration) Interval {
+	var avg float64
+	if d > 0 {
+		avg = b.inflightArea / float64(d)
+	}
+
+	latency, failures := NewHistogram(), NewHistogram()
+	latency.Merge(b.latency)
+	failures.Merge(b.failures)
+
+	return Interval{
+		Start:         start,
+		Duration:      d,
+		Requests:      b.requests,
+		Responses:     b.responses.sorted(),
+		SentBytes:     b.sent,
+		ReceivedBytes: b.received,
+		Latency:       latency,
+		Custom:        b.custom.snapshot(),
+		InflightMin:   b.inflightMin,
+		InflightAvg:   avg,
+		InflightMax:   b.inflightMax,
+		failures:      failures,
+	}
+}
+
+// timeline is the part of the run timeline recorded by a single shard. It
+// keeps buckets from base on, older ones are flushed to the recorder. It is
+// not safe for concurrent use.
+type timeline struct {
+	start    time.Time
+	interval time.Duration
+	base     int
+	buckets  []*bucket
+
+	// inflight is the number of the shard outstanding requests since inflightAt,
+	// the time since the start.
+	inflight   int64
+	inflightAt time.Duration
+
+	// last is the index of the last bucket found, which starts at lastFrom.
+	last     int
+	lastFrom time.Duration
+}
+
+func newTimeline(interval time.Duration) *timeline {
+	return &timeline{interval: interval}
+}
+
+func (t *timeline) reset(start time.Time, interval time.Duration) {
+	t.start = start
+	t.interval = interval
+	t.base = 0
+	t.buckets = nil
+	t.inflightAt = 0
+	t.last, t.lastFrom = 0, 0
+}
+
+func (t *timeline) bucketIndex(ts time.Time) int {
+	return t.index(ts.Sub(t.start))
+}
+
+// index returns the index of the bucket of the moment at since the start.
+func (t *timeline) index(at time.Duration) int {
+	// NOTE: moments mostly fall into the last bucket found, which saves a division
+	if at >= t.lastFrom && at-t.lastFrom < t.interval {
+		return t.last
+	}
+
+	t.last = int(at / t.interval)
+	t.lastFrom = time.Duration(t.last) * t.interval
+
+	return t.last
+}
+
+// ensure creates buckets up to the index i.
+func (t *timeline) ensure(i int) {
+	for t.base+len(t.buckets) <= i {
+		b := newBucket()
+		// NOTE: the number did not change since inflightAt, which is before the bucket
+		b.inflightStart = t.inflight
+		t.buckets = append(t.buckets, b)
+	}
+}
+
+// at returns the bucket for the moment ts. Moments of flushed buckets go to
+// the oldest kept one.
+func (t *timeline) at(ts time.Time) *bucket {
+	if t.start.IsZero() || ts.Before(t.start) {
+		return nil
+	}
+
+	return t.atIndex(t.bucketIndex(ts))
+}
+
+// atIndex returns the bucket i, or the oldest kept one if it was flushed.
+func (t *timeline) atIndex(i int) *bucket {
+	i = max(i, t.base)
+	t.ensure(i)
+
+	return t.buckets[i-t.base]
+}
+
+func (t *timeline) addRequests(ts time.Time, n int64) {
+	if b := t.at(ts); b != nil {
+		b.requests += n
+	}
+}
+
+func (t *timeline) addResponse(ts time.Time, key responseKey, n int64, latency time.Duration) {
+	if b := t.at(ts); b != nil {
+		b.responses.add(key, n)
+		if latency >= 0 {
+			b.latency.Record(latency)
+			if !key.success {
+				b.failures.Record(latency)
+			}
+		}
+	}
+}
+
+func (t *timeline) addBytes(ts time.Time, sent, received uint64) {
+	if b := t.at(ts); b != nil {
+		b.sent += sent
+		b.received += received
+	}
+}
+
+func (t *timeline) addCustom(ts time.Time, kind MetricKind, name string, tags []Tag, v float64) {
+	if b := t.at(ts); b != nil {
+		b.custom.observe(ts, kind, name, tags, v)
+	}
+}
+
+// addInflight changes the shard outstanding requests by delta.
+func (t *timeline) addInflight(ts time.Time, delta int64) {
+	b := t.at(ts)
+	if b == nil {
+		t.inflight += delta
+
+		return
+	}
+
+	at := ts.Sub(t.start)
+	t.integrate(at)
+	t.inflight += delta
+	b.changeInflight(at, delta)
+}
+
+// addRequest records a request answered at e after latency at once, on its
+// completion. It was not counted by addInflight, so parts of it within
+// flushed buckets are lost, except the request itself and its response,
+// which go to the oldest kept bucket.
+func (t *timeline) addRequest(e time.Time, key responseKey, latency time.Duration) {
+	if t.start.IsZero() {
+		return
+	}
+	eAt := e.Sub(t.start)
+	if eAt < 0 {
+		return
+	}
+	sAt := eAt - latency
+
+	first, last := -1, t.index(eAt)
+	if j := last - t.base; sAt >= t.lastFrom && j >= 0 && j < len(t.buckets) {
+		// NOTE: requests mostly start and end within the current bucket
+		b := t.buckets[j]
+		b.requests++
+		b.responses.add(key, 1)
+		b.latency.Record(latency)
+		if !key.success {
+			b.failures.Record(latency)
+		}
+
+		t.integrate(eAt)
+		b.inflightArea += float64(latency)
+		b.changeInflight(sAt, 1)
+		b.changeInflight(eAt, -1)
+
+		return
+	}
+	if sAt >= 0 {
+		first = t.index(sAt)
+		t.atIndex(first).requests++
+	}
+
+	b := t.atIndex(last)
+	b.responses.add(key, 1)
+	b.latency.Record(latency)
+	if !key.success {
+		b.failures.Record(latency)
+	}
+
+	t.integrate(eAt)
+	if last < t.base {
+		return
+	}
+
+	for i := max(first, t.base); i <= last; i++ {
+		t.buckets[i-t.base].inflightArea += float64(t.overlap(i, sAt, eAt))
+		if i > first {
+			t.buckets[i-t.base].inflightStart++
+		}
+	}
+	if first >= t.base {
+		t.buckets[first-t.base].changeInflight(sAt, 1)
+	}
+	b.changeInflight(eAt, -1)
+}
+
+// integrate accounts the outstanding requests from inflightAt till at.
+func (t *timeline) integrate(at time.Duration) {
+	if at <= t.inflightAt {
+		return
+	}
+	if t.inflight == 0 {
+		t.inflightAt = at
+
+		return
+	}
+
+	t.accumulate(at)
+}
+
+// accumulate adds the outstanding requests from inflightAt till at to buckets.
+func (t *timeline) accumulate(at time.Duration) {
+	first := max(t.index(t.inflightAt), t.base)
+	if last := t.base + len(t.buckets) - 1; first == last && t.inflightAt > time.Duration(first)*t.interval {
+		// NOTE: changes mostly happen within the current bucket
+		t.buckets[last-t.base].inflightArea += float64(t.inflight) * float64(at-t.inflightAt)
+		t.inflightAt = at
+
+		return
+	}
+
+	for i := first; i < t.base+len(t.buckets); i++ {
+		t.buckets[i-t.base].inflightArea += float64(t.inflight) * float64(t.overlap(i, t.inflightAt, at))
+	}
+	t.inflightAt = at
+}
+
+// overlap returns the part of [from, to) within the bucket i, all of them
+// are times since the start.
+func (t *timeline) overlap(i int, from, to time.Duration) time.Duration {
+	start := time.Duration(i) * t.interval
+
+	return max(min(to, start+t.interval)-max(from, start), 0)
+}
+
+// size returns the number of buckets recorded, flushed ones included.
+func (t *timeline) size() int {
+	return t.base + len(t.buckets)
+}
+
+// flush removes and returns buckets before the index upTo, complete ones.
+// Outstanding requests are accounted till the end of the last one, or till
+// end if it is earlier, e.g. the stop time.
+func (t *timeline) flush(upTo int, end time.Time) []*bucket {
+	if t.start.IsZero() || upTo <= t.base {
+		return nil
+	}
+
+	t.ensure(upTo - 1)
+	to := time.Duration(upTo) * t.interval
+	if !end.IsZero() {
+		to = min(to, end.Sub(t.start))
+	}
+	t.integrate(to)
+
+	n := upTo - t.base
+	res := t.buckets[:n:n]
+	t.buckets = t.buckets[n:]
+	t.base = upTo
+
+	return res
+}
+
+// pending returns copies of the kept buckets up to the index last, as if
+// the outstanding requests lasted till end.
+func (t *timeline) pending(last int, end time.Time) []*bucket {
+	if t.start.IsZero() || last < t.base {
+		return nil
+	}
+
+	t.ensure(last)
+
+	res := make([]*bucket, last-t.base+1)
+	for j := range res {
+		i := t.base + j
+		res[j] = t.buckets[j].copy()
+		res[j].inflightArea += float64(t.inflight) * float64(t.overlap(i, t.inflightAt, end.Sub(t.start)))
+	}
+
+	return res
+}
+
+// mergeLatency returns the latency histogram of all intervals.
+func mergeLatency(intervals []Interval) *Histogram {
+	h := NewHistogram()
+	for _, i := range intervals {
+		h.Merge(i.Latency)
+	}
+
+	return h
+}
+
+func sortedResponses(m map[responseKey]int64) []Response {
+	res := make([]Response, 0, len(m))
+	for k, c := range m {
+		res = append(res, Response{Code: k.code, Success: k.success, Count: c})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Code != res[j].Code {
+			return res[i].Code < res[j].Code
+		}
+
+		return res[i].Success && !res[j].Success
+	})
+
+	return res
+}
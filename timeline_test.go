@@ -0,0 +1,190 @@
+package stinger
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelineSnapshot(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(time.Second)
+	s := r.newShard()
+
+	s.addRequests(start, 1)
+	assert.Empty(t, r.snapshotFrom(0), "not started timeline must not record")
+
+	r.reset(start)
+	s.addRequests(start, 2)
+	s.addResponses(start.Add(100*time.Millisecond), "OK", true, 1)
+	s.endRequest(start.Add(100*time.Millisecond), 10*time.Millisecond, "OK", true, "", nil)
+	s.addResponses(start.Add(2500*time.Millisecond), "Unavailable", false, 1)
+	s.addBytes(start.Add(2500*time.Millisecond), 10, 20)
+
+	r.stop(start.Add(2750 * time.Millisecond))
+	res := r.snapshotFrom(0)
+	assert.Len(t, res, 3)
+
+	assert.Equal(t, start, res[0].Start)
+	assert.Equal(t, int64(2), res[0].Requests)
+	assert.Equal(t, []Response{{Code: "OK", Success: true, Count: 2}}, res[0].Responses)
+	assert.Equal(t, int64(1), res[0].Latency.Count())
+	assert.InDelta(t, 2.0, res[0].Throughput(), 0.001)
+
+	assert.Equal(t, int64(0), res[1].Requests)
+	assert.Empty(t, res[1].Responses)
+
+	assert.Equal(t, 750*time.Millisecond, res[2].Duration)
+	assert.Equal(t, uint64(10), res[2].SentBytes)
+	assert.Equal(t, uint64(20), res[2].ReceivedBytes)
+	assert.InDelta(t, 1/0.75, res[2].Throughput(), 0.001)
+
+	assert.Len(t, r.snapshotFrom(2), 1)
+	assert.Empty(t, r.snapshotFrom(3))
+}
+
+func TestTimelineShards(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(time.Hour)
+	a, b := r.newShard(), r.newShard()
+	r.reset(start)
+
+	a.addRequests(time.Now(), 1)
+	b.addRequests(time.Now(), 2)
+	res := r.snapshotFrom(0)
+	assert.Len(t, res, 1)
+	assert.Equal(t, int64(3), res[0].Requests)
+
+	// NOTE: snapshots of a running timeline must not consume the current interval
+	a.addRequests(time.Now(), 1)
+	c := r.newShard()
+	c.addRequests(time.Now(), 1)
+	res = r.snapshotFrom(0)
+	assert.Len(t, res, 1)
+	assert.Equal(t, int64(5), res[0].Requests)
+
+	r.stop(time.Now())
+	res = r.snapshotFrom(0)
+	assert.Len(t, res, 1)
+	assert.Equal(t, int64(5), res[0].Requests)
+	assert.Equal(t, int64(5), r.snapshot().requests)
+}
+
+func TestTimelineInflight(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(time.Second)
+	a, b := r.newShard(), r.newShard()
+
+	a.startRequest(start.Add(-time.Second))
+	r.reset(start)
+	b.startRequest(start.Add(500 * time.Millisecond))
+	a.endRequest(start.Add(1500*time.Millisecond), 2500*time.Millisecond, "OK", true, "", nil)
+	b.endRequest(start.Add(1500*time.Millisecond), time.Second, "OK", true, "", nil)
+	b.addRequests(start.Add(2200*time.Millisecond), 1)
+
+	r.stop(start.Add(2500 * time.Millisecond))
+	res := r.snapshotFrom(0)
+	assert.Len(t, res, 3)
+
+	assert.Equal(t, int64(1), res[0].InflightMin)
+	assert.Equal(t, int64(2), res[0].InflightMax)
+	assert.InDelta(t, 1.5, res[0].InflightAvg, 0.001)
+
+	assert.Equal(t, int64(0), res[1].InflightMin)
+	assert.Equal(t, int64(2), res[1].InflightMax)
+	assert.InDelta(t, 1, res[1].InflightAvg, 0.001)
+
+	assert.Equal(t, int64(0), res[2].InflightMax)
+	assert.Zero(t, res[2].InflightAvg)
+}
+
+func TestTimelineCompleteRequest(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(time.Second)
+	a, b := r.newShard(), r.newShard()
+	r.reset(start)
+
+	a.completeRequest(start.Add(1500*time.Millisecond), time.Second, "OK", true, "", nil)
+	b.startRequest(start.Add(200 * time.Millisecond))
+	b.completeRequest(start.Add(1400*time.Millisecond), 200*time.Millisecond, "OK", true, "", nil)
+	b.endRequest(start.Add(1800*time.Millisecond), 1600*time.Millisecond, "OK", true, "", nil)
+
+	r.stop(start.Add(2 * time.Second))
+	res := r.snapshotFrom(0)
+	assert.Len(t, res, 2)
+
+	assert.Equal(t, int64(2), res[0].Requests)
+	assert.Equal(t, int64(1), res[1].Requests)
+	assert.InDelta(t, 3, res[1].Throughput()*res[1].Duration.Seconds(), 0.001)
+	assert.InDelta(t, 1.3, res[0].InflightAvg, 0.001)
+	assert.InDelta(t, 1.5, res[1].InflightAvg, 0.001)
+	assert.Equal(t, int64(2), res[0].InflightMax)
+	assert.Equal(t, int64(3), res[1].InflightMax, "all three are outstanding over [1.2s, 1.4s)")
+	assert.Equal(t, int64(3), r.snapshot().requests)
+}
+
+func TestTimelineInflightRunWide(t *testing.T) {
+	start := time.Now()
+	r := newRecorder(time.Second)
+	shards := make([]*shard, 50)
+	for i := range shards {
+		shards[i] = r.newShard()
+	}
+	r.reset(start)
+
+	// NOTE: every actor pauses for 1ms once, at its own time
+	end := start.Add(1500 * time.Millisecond)
+	for i, s := range shards {
+		pause := start.Add(time.Duration(100+10*i) * time.Millisecond)
+		s.completeRequest(pause, pause.Sub(start), "OK", true, "", nil)
+		s.completeRequest(end, end.Sub(pause)-time.Millisecond, "OK", true, "", nil)
+	}
+
+	r.stop(start.Add(2 * time.Second))
+	res := r.snapshotFrom(0)
+	assert.Len(t, res, 2)
+	assert.Equal(t, int64(49), res[0].InflightMin)
+	assert.Equal(t, int64(50), res[0].InflightMax)
+}
+
+func TestTimelineInflightStopped(t *testing.T) {
+	for _, interval := range []time.Duration{100 * time.Millisecond, time.Hour} {
+		start := time.Now()
+		r := newRecorder(interval)
+		s := r.newShard()
+		r.reset(start)
+
+		s.startRequest(start)
+		r.stop(start.Add(10 * time.Millisecond))
+
+		res := r.snapshotFrom(0)
+		assert.Len(t, res, 1)
+		assert.Equal(t, 10*time.Millisecond, res[0].Duration)
+		assert.InDelta(t, 1, res[0].InflightAvg, 0.001, "the request is outstanding till the stop")
+		assert.Equal(t, int64(1), res[0].InflightMin)
+		assert.Equal(t, int64(1), res[0].InflightMax)
+	}
+}
+
+func TestInflightRunOrder(t *testing.T) {
+	var r inflightRun
+	// NOTE: starts of synchronous requests come on completion, after later changes
+	for i := range 3 * inflightChunk {
+		at := time.Duration(i) * time.Millisecond
+		r.add(makeInflightChange(at, -1))
+		if i%3 == 2 {
+			r.add(makeInflightChange(at-2*time.Millisecond-time.Microsecond, 1))
+		}
+	}
+
+	var got []time.Duration
+	for _, c := range r.chunks() {
+		for _, x := range c {
+			got = append(got, x.at())
+		}
+	}
+	assert.Len(t, got, 4*inflightChunk)
+	assert.True(t, slices.IsSorted(got))
+}
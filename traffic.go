@@ -0,0 +1,207 @@
+package stinger
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TrafficStats is the traffic of a target URI, a runner or the whole run.
+type TrafficStats struct {
+	// Name is the target URI or the runner name.
+	Name          string
+	SentBytes     uint64
+	ReceivedBytes uint64
+	// Connections is the number of established connections.
+	Connections int64
+	// Active is the number of connections open at the moment of the snapshot.
+	Active       int64
+	DialFailures int64
+	// Lifetime is the lifetime distribution of closed connections.
+	Lifetime *Histogram
+}
+
+// traffic counts bytes and connections. Only the lifetime needs the lock.
+type traffic struct {
+	sent         atomic.Uint64
+	received     atomic.Uint64
+	conns        atomic.Int64
+	active       atomic.Int64
+	dialFailures atomic.Int64
+
+	mu       sync.Mutex
+	lifetime *Histogram
+}
+
+func newTraffic() *traffic {
+	return &traffic{lifetime: NewHistogram()}
+}
+
+// reset zeroes everything but the active connections, which are still open.
+func (t *traffic) reset() {
+	t.sent.Store(0)
+	t.received.Store(0)
+	t.conns.Store(0)
+	t.dialFailures.Store(0)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lifetime = NewHistogram()
+}
+
+func (t *traffic) closed(lifetime time.Duration) {
+	t.active.Add(-1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lifetime.Record(lifetime)
+}
+
+func (t *traffic) snapshot(name string) TrafficStats {
+	t.mu.Lock()
+	lifetime := NewHistogram()
+	lifetime.Merge(t.lifetime)
+	t.mu.Unlock()
+
+	return TrafficStats{
+		Name:          name,
+		SentBytes:     t.sent.Load(),
+		ReceivedBytes: t.received.Load(),
+		Connections:   t.conns.Load(),
+		Active:        t.active.Load(),
+		DialFailures:  t.dialFailures.Load(),
+		Lifetime:      lifetime,
+	}
+}
+
+// trafficStats is the run traffic in total, per target and per runner.
+type trafficStats struct {
+	total *traffic
+
+	mu      sync.Mutex
+	targets map[string]*traffic
+	runners map[string]*traffic
+}
+
+func newTrafficStats() *trafficStats {
+	return &trafficStats{
+		total:   newTraffic(),
+		targets: make(map[string]*traffic),
+		runners: make(map[string]*traffic),
+	}
+}
+
+func (s *trafficStats) get(m map[string]*traffic, name string) *traffic {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := m[name]
+	if !ok {
+		t = newTraffic()
+		m[name] = t
+	}
+
+	return t
+}
+
+func (s *trafficStats) target(uri string) *traffic {
+	return s.get(s.targets, uri)
+}
+
+func (s *trafficStats) runner(name string) *traffic {
+	return s.get(s.runners, name)
+}
+
+func (s *trafficStats) reset() {
+	s.total.reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.targets {
+		t.reset()
+	}
+	for _, t := range s.runners {
+		t.reset()
+	}
+}
+
+// targetNames returns URIs of all targets seen.
+func (s *trafficStats) targetNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]string, 0, len(s.targets))
+	for name := range s.targets {
+		res = append(res, name)
+	}
+
+	return res
+}
+
+func snapshotTraffic(m map[string]*traffic) []TrafficStats {
+	res := make([]TrafficStats, 0, len(m))
+	for name, t := range m {
+		res = append(res, t.snapshot(name))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+func (s *trafficStats) snapshot() (TrafficStats, []TrafficStats, []TrafficStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.total.snapshot(""), snapshotTraffic(s.targets), snapshotTraffic(s.runners)
+}
+
+// ownTraffic returns the total counters and those of the runner of m.
+func (m *Metrics) ownTraffic() []*traffic {
+	if m.runner == nil {
+		return []*traffic{m.traffic.total}
+	}
+
+	return []*traffic{m.traffic.total, m.runner}
+}
+
+// trafficOf returns counters of the target and the runner of m, the total included.
+func (m *Metrics) trafficOf(target string) []*traffic {
+	return append(m.ownTraffic(), m.traffic.target(target))
+}
+
+// addTraffic counts bytes of the actor in the timeline and the counters.
+func (m *Metrics) addTraffic(sent, received uint64, counters ...*traffic) {
+	for _, t := range counters {
+		t.sent.Add(sent)
+		t.received.Add(received)
+	}
+
+	m.shard.addBytes(time.Now(), sent, received)
+	m.bytes.add(sent, received)
+}
+
+// ObserveDialFailure counts a failed attempt to connect to the target.
+func (m *Metrics) ObserveDialFailure(target string) {
+	for _, t := range m.trafficOf(target) {
+		t.dialFailures.Add(1)
+	}
+}
+
+// NewSizeObserverNetConn counts traffic of c, established to the target, in m.
+func NewSizeObserverNetConn(m *Metrics, c net.Conn, target string) *SizeObserverNetConn {
+	counters := m.trafficOf(target)
+	for _, t := range counters {
+		t.conns.Add(1)
+		t.active.Add(1)
+	}
+
+	return &SizeObserverNetConn{c: c, m: m, counters: counters, opened: time.Now()}
+}
@@ -2,7 +2,6 @@ package stinger
 
 import (
 	"fmt"
-	"io"
 	"math/rand"
 	"sync"
 )
@@ -119,25 +118,3 @@ func ByteCountIEC(b uint64) string {
 	return fmt.Sprintf("%.1f %ciB",
 		float64(b)/float64(div), "KMGTPE"[exp])
 }
-
-func observingSizeRead(m *Metrics, r io.Reader, b []byte) (int, error) {
-	n, err := r.Read(b)
-	if err != nil {
-		return 0, err
-	}
-	//nolint:gosec
-	m.AddReceivedBytes(uint64(n))
-
-	return n, err
-}
-
-func observingSizeWrite(m *Metrics, w io.Writer, b []byte) (int, error) {
-	n, err := w.Write(b)
-	if err != nil {
-		return 0, err
-	}
-	//nolint:gosec
-	m.AddSentBytes(uint64(n))
-
-	return n, err
-}